@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/zrs01/aichatconf/internal/aichat"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// clientTypes are the types of the clients of aichat, and ollama.
+var clientTypes = []string{
+	"openai", "openai-compatible", "gemini", "claude", "cohere", "azure-openai", "vertexai", "bedrock",
+	"cloudflare", "ernie", "qianwen", "ollama", "lmstudio",
+}
+
+// addClient appends a client of --type and --name to the clients of the config, the rest of the config is
+// kept with the comments. The models are synced into the client with --sync if the type has a model source.
+func addClient() error {
+	if !lo.Contains(clientTypes, optClientType) {
+		return tracerr.Errorf("unknown client type (%s), one of %s", optClientType, strings.Join(clientTypes, ", "))
+	}
+	if optClientName == "" {
+		// aichat names a client by its type if the name is absent
+		optClientName = optClientType
+	}
+	if optClientType == "openai-compatible" && optAPIBase == "" {
+		return tracerr.New("api_base is required by openai-compatible, use --api-base")
+	}
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	root := cfg.doc.Content[0]
+	if cfg.malformedClients() {
+		return tracerr.Errorf("clients is not a list in config (%s)", optCfgFile)
+	}
+	if cfg.clients == nil {
+		cfg.clients = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		setNodeField(root, "clients", cfg.clients)
+	}
+	for _, cn := range cfg.clients.Content {
+		if clientName(cn) == optClientName {
+			return tracerr.Errorf("client already exists: %s", optClientName)
+		}
+	}
+
+	var clientNode yaml.Node
+	if err := clientNode.Encode(aichat.Client{Type: optClientType, Name: optClientName, APIBase: optAPIBase, APIKey: optAPIKey}); err != nil {
+		return tracerr.Wrap(err)
+	}
+	cfg.clients.Content = append(cfg.clients.Content, &clientNode)
+	// an empty list is "[]" in flow style, e.g. by remove-client
+	cfg.clients.Style = 0
+	verboseInfo("add client: %s (%s)", optClientName, optClientType)
+
+	sourceName := getSourceName(&clientNode)
+	if !optAddSync || (sourceName == "ollama" && optClientType != "ollama") {
+		if optAddSync {
+			verboseInfo("models not synced, no model source of client type: %s", optClientType)
+		}
+		return cfg.write(syncSummary{clients: 1}, "")
+	}
+	body, err := cfg.marshal()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	outstr := strings.TrimSpace(string(body))
+
+	// sync the models into the new client as init does, the config is only written if the sync succeeds
+	added, err := os.CreateTemp("", "aichatconf-add-client-*.yaml")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer os.Remove(added.Name())
+	if _, err := added.WriteString(outstr + "\n"); err != nil {
+		added.Close()
+		return tracerr.Wrap(err)
+	}
+	if err := added.Close(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	// api_base and api_key are in the client now, the placeholders are expanded when connecting
+	optAPIBase, optAPIKey = "", ""
+	// the config is written by the sync, confirmed as the sync does
+	optCfgFile = added.Name()
+	return process()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAddClientWritePath(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := ollamaConfig(mock.URL)
+	cfgFile := writeFile(t, "config.yaml", config)
+	readConfig := func() string {
+		t.Helper()
+		body, err := os.ReadFile(cfgFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	add := []string{"add-client", "-c", cfgFile, "-o", cfgFile, "--type", "openai-compatible", "--name", "vllm", "--api-base", "http://localhost:8000/v1"}
+	// --dry-run prints the config and keeps the file
+	res := runMain(t, "", append(add, "--dry-run")...)
+	if res.code != exitOK || !strings.Contains(res.stdout, "name: vllm") || readConfig() != config {
+		t.Fatalf("dry run, exit code %d: %s\n%s", res.code, res.stderr, res.stdout)
+	}
+	res = runMain(t, "", append(add, "--yes", "-v")...)
+	if res.code != exitOK || res.stdout != "" || !strings.Contains(readConfig(), "name: vllm") {
+		t.Fatalf("exit code %d: %s\n%s", res.code, res.stderr, res.stdout)
+	}
+
+	// the output is verified
+	invalid := writeFile(t, "config.yaml", config+"  - type: openai\n    name: other\n    models:\n      - name: m\n      - name: m\n")
+	addInvalid := []string{"add-client", "-c", invalid, "--type", "openai", "--name", "new"}
+	res = runMain(t, "", addInvalid...)
+	if res.code != exitInvalid || res.stdout != "" {
+		t.Errorf("exit code %d, stdout %q: %s", res.code, res.stdout, res.stderr)
+	}
+	res = runMain(t, "", append(addInvalid, "--no-verify")...)
+	if res.code != exitOK || !strings.Contains(res.stdout, "name: other") {
+		t.Errorf("--no-verify, exit code %d: %s", res.code, res.stderr)
+	}
+}
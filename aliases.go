@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// modelAliases maps the aliases of --alias and --alias-file to the names of the models on the server.
+var modelAliases = map[string]string{}
+
+// loadAliases reads the aliases of --alias-file, a map of the aliases to the models like
+//
+//	work-coder: qwen2.5-coder:32b
+//
+// and then the aliases of --alias, alias=model, overriding the file.
+func loadAliases() error {
+	modelAliases = map[string]string{}
+	if optAliasFile != "" {
+		body, err := os.ReadFile(optAliasFile)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := yaml.Unmarshal(body, &modelAliases); err != nil {
+			return tracerr.Errorf("invalid alias file (%s): %w", optAliasFile, err)
+		}
+		if modelAliases == nil {
+			modelAliases = map[string]string{}
+		}
+	}
+	for _, entry := range optAliases {
+		alias, model, ok := strings.Cut(entry, "=")
+		if !ok {
+			return tracerr.Errorf("invalid alias, alias=model is expected: %s", entry)
+		}
+		modelAliases[strings.TrimSpace(alias)] = strings.TrimSpace(model)
+	}
+	for alias, model := range modelAliases {
+		if alias == "" || model == "" {
+			return tracerr.Errorf("invalid alias, alias and model are required: %s=%s", alias, model)
+		}
+	}
+	if len(modelAliases) > 0 {
+		verboseInfo("aliases found: %d", len(modelAliases))
+	}
+	return nil
+}
+
+// stripTagAliases adds the aliases of --strip-tag, the names without the tags, to the models of the server.
+// Of the models of the same name without the tag, the most recently modified one is kept and the others are
+// dropped. A model having an alias already is not stripped.
+func stripTagAliases(models []ollamaModel) []ollamaModel {
+	kept := map[string]ollamaModel{}
+	dropped := []string{}
+	for _, model := range models {
+		// a model without the tag, e.g. by --strip-latest, competes for its name as well
+		base, _, _ := strings.Cut(model.name, ":")
+		if aliasOf(model.name) != "" {
+			continue
+		}
+		if _, ok := modelAliases[base]; ok {
+			verboseDebug("tag not stripped, alias present: %s", model.name)
+			continue
+		}
+		current, ok := kept[base]
+		if !ok {
+			kept[base] = model
+			continue
+		}
+		// the time of modification is zero of the sources not listing the details, the first one is kept
+		if model.details.modifiedAt.After(current.details.modifiedAt) {
+			kept[base], model = model, current
+		}
+		dropped = append(dropped, model.name)
+		verboseInfo("drop model of the same name without the tag: %s, kept %s", model.name, kept[base].name)
+	}
+	for base, model := range kept {
+		if base != model.name {
+			modelAliases[base] = model.name
+		}
+	}
+	return lo.Filter(models, func(model ollamaModel, _ int) bool { return !lo.Contains(dropped, model.name) })
+}
+
+// applyAliases replaces the models of the server having aliases with their aliases in order.
+func applyAliases(models []string) []string {
+	aliases := map[string][]string{}
+	for alias, model := range modelAliases {
+		aliases[model] = append(aliases[model], alias)
+	}
+	result := []string{}
+	for _, model := range models {
+		if names, ok := aliases[model]; ok {
+			sort.Strings(names)
+			result = append(result, names...)
+			continue
+		}
+		result = append(result, model)
+	}
+	return result
+}
+
+// realModelName returns the name of the model on the server, the model itself if not an alias.
+func realModelName(model string) string {
+	if name, ok := modelAliases[model]; ok {
+		return name
+	}
+	return model
+}
+
+// setAliasPatch sets the model of the alias in the request body patch of the model node,
+// so aichat requests the model on the server under the alias.
+func setAliasPatch(node *yaml.Node, alias string) {
+	model, ok := modelAliases[alias]
+	if !ok {
+		return
+	}
+	api := "chat_completions"
+	if modelType, ok := getNodeValue(node, "type", yaml.ScalarNode); ok {
+		switch modelType.Value {
+		case "embedding":
+			api = "embeddings"
+		case "reranker":
+			api = "rerank"
+		}
+	}
+	body := node
+	for _, key := range []string{"patch", api, ".*", "body"} {
+		body = getOrAddMapping(body, key)
+	}
+	setNodeField(body, "model", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: model})
+}
+
+// aliasOf returns the first alias of the model on the server in order, empty if none.
+func aliasOf(model string) string {
+	aliases := []string{}
+	for alias, name := range modelAliases {
+		if name == model {
+			aliases = append(aliases, alias)
+		}
+	}
+	if len(aliases) == 0 {
+		return ""
+	}
+	sort.Strings(aliases)
+	return aliases[0]
+}
+
+// patchedModelName returns the model requested by the request body patch of the model node, e.g. of an alias,
+// empty if not patched.
+func patchedModelName(node *yaml.Node) string {
+	for _, api := range []string{"chat_completions", "embeddings", "rerank"} {
+		body := node
+		for _, key := range []string{"patch", api, ".*", "body"} {
+			if body, _ = getNodeValue(body, key, yaml.MappingNode); body == nil {
+				break
+			}
+		}
+		if body == nil {
+			continue
+		}
+		if model, ok := getNodeValue(body, "model", yaml.ScalarNode); ok {
+			return model.Value
+		}
+	}
+	return ""
+}
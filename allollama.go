@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+)
+
+// syncAllOllama syncs the clients of type ollama one after another into the config, --client is ignored.
+// A client failing to sync is left unchanged and the others are synced, the summary of each client is
+// reported and the failures are returned together after the config is written.
+func syncAllOllama() error {
+	if optCheck || optDiffOnly || !isConfigFormat() {
+		return tracerr.New("--all-ollama cannot be used with --check, --diff-only or the formats of the models")
+	}
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	_, names := cfg.ollamaClients()
+	if len(names) == 0 {
+		return tracerr.New("no client of type ollama")
+	}
+
+	// each client is synced into the work file as a sync of its own
+	work, err := os.CreateTemp("", "aichatconf-all-ollama-*.yaml")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer os.Remove(work.Name())
+	if _, err := work.Write(cfg.origBody); err != nil {
+		work.Close()
+		return tracerr.Wrap(err)
+	}
+	if err := work.Close(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	cfgFile, outFile, format, yes, dryRun, onChange := optCfgFile, optOutFile, optFormat, optYes, optDryRun, optOnChange
+	// the command of --on-change is run once for the config, not for the work file of each client
+	optCfgFile, optOutFile, optFormat, optYes, optDryRun, optOnChange = work.Name(), work.Name(), "yaml", true, false, ""
+	// restore the options on the failures too, the work file is removed then
+	defer func() {
+		optCfgFile, optOutFile, optFormat, optYes, optDryRun, optOnChange = cfgFile, outFile, format, yes, dryRun, onChange
+	}()
+	var total syncSummary
+	var errs []error
+	summaries := map[string]syncSummary{}
+	for _, name := range names {
+		optClientName = name
+		lastSummary = syncSummary{}
+		if err := process(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		total = total.plus(lastSummary)
+		summaries[name] = lastSummary
+	}
+	optFormat, optYes, optDryRun, optOnChange = format, yes, dryRun, onChange
+
+	// write the config of all the clients as a sync does
+	synced, err := loadAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	optCfgFile, optOutFile = cfgFile, outFile
+	body, err := synced.marshal()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for _, name := range names {
+		if summary, ok := summaries[name]; ok {
+			optClientName = name
+			logSummary(summary, "client %s: %s", name, summary)
+		} else {
+			logrus.Warnf("client %s: failed, unchanged", name)
+		}
+	}
+	if err := writeOutput(strings.TrimSpace(string(body)), total); err != nil {
+		return tracerr.Wrap(err)
+	}
+	lastSummary = total
+	if len(errs) > 0 {
+		return tracerr.Errorf("%d of %d clients failed: %w", len(errs), len(names), errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// annotationPattern matches the comments written by --annotate, e.g. "# 14.8B, Q4_K_M, 9.0 GB".
+var annotationPattern = regexp.MustCompile(`^# ([^,]+, )*\d+(\.\d+)? (B|kB|MB|GB|TB)$`)
+
+// annotateModels sets the line comment of the name of each model to the parameter size, the quantization and
+// the file size listed by the server, or removes the comments of --annotate with --annotate=false. The comments
+// of the user are kept.
+func annotateModels(models *yaml.Node) {
+	src, ok := modelSrc.(detailedSource)
+	for _, cfgModel := range models.Content {
+		name, found := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+		if !found || (name.LineComment != "" && !annotationPattern.MatchString(name.LineComment)) {
+			continue
+		}
+		if !optAnnotate {
+			name.LineComment = ""
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if details, found := src.modelDetails(realModelName(name.Value)); found && details.size > 0 {
+			parts := []string{}
+			for _, part := range []string{details.parameterSize, details.quantization, formatSize(details.size)} {
+				if part != "" {
+					parts = append(parts, part)
+				}
+			}
+			name.LineComment = "# " + strings.Join(parts, ", ")
+		}
+	}
+}
+
+// formatSize formats the size in bytes in the decimal units, e.g. 9.0 GB.
+func formatSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// autoDefaultStrategies are the strategies of --auto-default.
+var autoDefaultStrategies = []string{"largest-context", "newest", "largest"}
+
+// autoDefaultModel returns the default model chosen by the strategy among the chat models of the client
+// available on the server, and the reason of the choice. Ties break alphabetically, empty if none.
+func autoDefaultModel(models *yaml.Node, available []string, strategy string) (string, string, error) {
+	if !lo.Contains(autoDefaultStrategies, strategy) {
+		return "", "", tracerr.Errorf("unknown auto-default strategy: %s", strategy)
+	}
+	candidates := []string{}
+	for _, cfgModel := range models.Content {
+		cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+		modelType, _ := getNodeValue(cfgModel, "type", yaml.ScalarNode)
+		if ok && lo.Contains(available, cfgModelName.Value) && (modelType == nil || modelType.Value == "chat") {
+			candidates = append(candidates, cfgModelName.Value)
+		}
+	}
+	sort.Strings(candidates)
+
+	chosen, reason := "", ""
+	best := 0.0
+	for _, model := range candidates {
+		var value float64
+		var desc string
+		switch strategy {
+		case "largest-context":
+			params, err := getModelParameters(model)
+			if err != nil || params.maxContextLength <= 0 {
+				continue
+			}
+			value = float64(params.maxContextLength)
+			desc = fmt.Sprintf("context length %d", params.maxContextLength)
+		case "newest", "largest":
+			src, ok := modelSrc.(detailedSource)
+			if !ok {
+				return "", "", tracerr.Errorf("auto-default strategy %s is not supported by source: %s", strategy, modelSrc.name())
+			}
+			details, ok := src.modelDetails(realModelName(model))
+			if !ok {
+				continue
+			}
+			if strategy == "newest" {
+				value = float64(details.modifiedAt.Unix())
+				desc = fmt.Sprintf("modified at %s", details.modifiedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				value = details.parameterCount
+				desc = fmt.Sprintf("%.1fB parameters", details.parameterCount/1e9)
+			}
+		}
+		if value > best {
+			chosen, reason, best = model, desc, value
+		}
+	}
+	return chosen, reason, nil
+}
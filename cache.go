@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	olmmodel "github.com/ollama/ollama/types/model"
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+)
+
+const showCacheFile = "show-cache.json"
+
+// showCache is the on-disk cache of the model parameters extracted from the show responses,
+// the entry of a model is valid while the digest of the model is unchanged.
+type showCache struct {
+	path    string
+	entries map[string]showCacheEntry
+	dirty   bool
+}
+
+type showCacheEntry struct {
+	Digest          string                `json:"digest"`
+	ContextLength   int                   `json:"context_length"`
+	MaxOutputTokens int                   `json:"max_output_tokens"`
+	EmbeddingLength int                   `json:"embedding_length"`
+	Temperature     float64               `json:"temperature"`
+	TopP            float64               `json:"top_p"`
+	Capabilities    []olmmodel.Capability `json:"capabilities,omitempty"`
+	Reranker        bool                  `json:"reranker,omitempty"`
+	Parameters      map[string][]string   `json:"parameters,omitempty"`
+}
+
+// loadShowCache loads the cache in the directory, default $XDG_CACHE_HOME/aichatconf. A missing or
+// corrupted cache file is started over.
+func loadShowCache(dir string) (*showCache, error) {
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		dir = filepath.Join(cacheDir, "aichatconf")
+	}
+	cache := &showCache{path: filepath.Join(dir, showCacheFile), entries: map[string]showCacheEntry{}}
+	body, err := os.ReadFile(cache.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logrus.Warnf("show cache ignored: %v", err)
+		}
+		return cache, nil
+	}
+	if err := json.Unmarshal(body, &cache.entries); err != nil {
+		logrus.Warnf("show cache corrupted, rebuilding: %s", cache.path)
+		cache.entries = map[string]showCacheEntry{}
+	}
+	verboseDebug("show cache loaded: %s, %d entries", cache.path, len(cache.entries))
+	return cache, nil
+}
+
+// get returns the cached parameters of the model with the digest.
+func (c *showCache) get(model, digest string) (*modelParameters, bool) {
+	entry, ok := c.entries[model]
+	if !ok || digest == "" || entry.Digest != digest {
+		return nil, false
+	}
+	return &modelParameters{
+		maxContextLength: entry.ContextLength,
+		maxOutputTokens:  entry.MaxOutputTokens,
+		embeddingLength:  entry.EmbeddingLength,
+		temperature:      entry.Temperature,
+		topP:             entry.TopP,
+		capabilities:     entry.Capabilities,
+		reranker:         entry.Reranker,
+		parameters:       entry.Parameters,
+	}, true
+}
+
+// put caches the parameters of the model with the digest.
+func (c *showCache) put(model, digest string, params *modelParameters) {
+	if digest == "" {
+		return
+	}
+	c.entries[model] = showCacheEntry{
+		Digest:          digest,
+		ContextLength:   params.maxContextLength,
+		MaxOutputTokens: params.maxOutputTokens,
+		EmbeddingLength: params.embeddingLength,
+		Temperature:     params.temperature,
+		TopP:            params.topP,
+		Capabilities:    params.capabilities,
+		Reranker:        params.reranker,
+		Parameters:      params.parameters,
+	}
+	c.dirty = true
+}
+
+// save writes the cache file if changed, a failure is only warned as the cache is optional.
+func (c *showCache) save() {
+	if !c.dirty {
+		return
+	}
+	body, err := json.MarshalIndent(c.entries, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(c.path), 0755); err == nil {
+			err = os.WriteFile(c.path, body, 0644)
+		}
+	}
+	if err != nil {
+		logrus.Warnf("show cache not saved: %v", err)
+		return
+	}
+	c.dirty = false
+	verboseDebug("show cache saved: %s, %d entries", c.path, len(c.entries))
+}
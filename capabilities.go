@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	olmmodel "github.com/ollama/ollama/types/model"
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// capabilityField maps a capability of ollama to the boolean field of the aichat model, an empty
+// field is a capability known but without a field, e.g. the embedding one sets the type instead.
+type capabilityField struct {
+	capability olmmodel.Capability
+	field      string
+}
+
+// capabilityFields are the capabilities known in the order of the fields written.
+var capabilityFields = []capabilityField{
+	{olmmodel.CapabilityVision, "supports_vision"},
+	{olmmodel.CapabilityTools, "supports_function_calling"},
+	{olmmodel.CapabilityThinking, "supports_reasoning"},
+	{olmmodel.CapabilityCompletion, ""},
+	{olmmodel.CapabilityEmbedding, ""},
+	{olmmodel.CapabilityInsert, ""},
+	{olmmodel.Capability("audio"), ""},
+}
+
+// loadCapabilityFields applies the mapping of --cap-fields-file and then --cap-field, capability=field, to the
+// capability fields. An empty field disables the capability.
+func loadCapabilityFields() error {
+	mappings := map[string]string{}
+	if optCapFieldsFile != "" {
+		body, err := os.ReadFile(optCapFieldsFile)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := yaml.Unmarshal(body, &mappings); err != nil {
+			return tracerr.Errorf("invalid capability fields file (%s): %w", optCapFieldsFile, err)
+		}
+	}
+	order := lo.Keys(mappings)
+	sort.Strings(order)
+	for _, value := range optCapFields {
+		capability, field, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(capability) == "" {
+			return tracerr.Errorf("invalid cap-field, capability=field: %s", value)
+		}
+		capability = strings.TrimSpace(capability)
+		if _, ok := mappings[capability]; !ok {
+			order = append(order, capability)
+		}
+		mappings[capability] = strings.TrimSpace(field)
+	}
+	for _, capability := range order {
+		field := mappings[capability]
+		i := slices.IndexFunc(capabilityFields, func(mapping capabilityField) bool { return string(mapping.capability) == capability })
+		if i < 0 {
+			capabilityFields = append(capabilityFields, capabilityField{olmmodel.Capability(capability), field})
+		} else {
+			capabilityFields[i].field = field
+		}
+		verboseDebug("capability field: %s=%s", capability, field)
+	}
+	return nil
+}
+
+// setCapabilityFields sets the fields of the capabilities of the model, the unknown capabilities are
+// only logged.
+func setCapabilityFields(node *yaml.Node, model string, capabilities []olmmodel.Capability) {
+	for _, mapping := range capabilityFields {
+		if mapping.field != "" && lo.Contains(capabilities, mapping.capability) {
+			setNodeKeyValue(node, yaml.ScalarNode, mapping.field, yaml.ScalarNode, "true")
+		}
+	}
+	for _, capability := range capabilities {
+		if !lo.ContainsBy(capabilityFields, func(mapping capabilityField) bool { return mapping.capability == capability }) {
+			verboseDebug("unknown capability of model %s skipped: %s", model, capability)
+		}
+	}
+}
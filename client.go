@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                        HTTP CLIENT WITH API KEY SUPPORT                    */
+/* -------------------------------------------------------------------------- */
+
+// apiKeyTransport adds the API_KEY header to every request.
+type apiKeyTransport struct {
+	rt     http.RoundTripper // the underlying transport
+	apiKey string            // the value you want to send
+	header string            // the header name, e.g. Authorization
+	scheme string            // the scheme before the key, e.g. Bearer, empty to send the raw key
+	user   *url.Userinfo     // the basic auth credentials from api_base, if any
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Clone the request so we don't mutate the caller's request
+	// (recommended by the net/http docs for RoundTripper wrappers).
+	req2 := req.Clone(req.Context())
+
+	// Basic auth from the api_base userinfo goes first, so the api key takes
+	// precedence when both are sent in the same header.
+	if t.user != nil {
+		password, _ := t.user.Password()
+		req2.SetBasicAuth(t.user.Username(), password)
+	}
+
+	// Add the header – you can use Add, Set or Direct assignment.
+	// Some proxies reject an empty token, so skip the header without api key.
+	if t.apiKey != "" {
+		if t.scheme != "" {
+			req2.Header.Set(t.header, fmt.Sprintf("%s %s", t.scheme, t.apiKey))
+		} else {
+			req2.Header.Set(t.header, t.apiKey)
+		}
+	}
+
+	// Pass the request on to the wrapped RoundTripper, timed for -vv.
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req2)
+	if err != nil {
+		verboseDebug("%s %s: failed in %s", req.Method, redactURL(req.URL.String()), time.Since(start).Round(time.Millisecond))
+	} else {
+		verboseDebug("%s %s: %s in %s", req.Method, redactURL(req.URL.String()), resp.Status, time.Since(start).Round(time.Millisecond))
+	}
+	return resp, err
+}
+
+// redactURL removes the credentials from the url for logging.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// lookupPrefixedEnv returns the value of environment variable <PREFIX>_<name> if it is set and not empty.
+func lookupPrefixedEnv(name string) (string, bool) {
+	if optEnvPrefix == "" {
+		return "", false
+	}
+	value := os.Getenv(optEnvPrefix + "_" + name)
+	return value, value != ""
+}
+
+// expandEnv expands ${VAR} and $VAR references in the value, unset variables result in error.
+func expandEnv(value string) (string, error) {
+	var missing []string
+	expanded := os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", tracerr.Errorf("environment variable not set: %s", strings.Join(lo.Uniq(missing), ", "))
+	}
+	return expanded, nil
+}
+
+// tlsOptions holds the TLS settings of the server connection.
+type tlsOptions struct {
+	caCert             string // CA certificate file appended to the root pool
+	clientCert         string // client certificate file for mTLS
+	clientKey          string // client key file for mTLS
+	insecureSkipVerify bool   // skip certificate verification
+}
+
+// getTLSOptions returns the TLS settings from the command line, or the extra mapping of the client if any.
+func getTLSOptions(cfgClient *yaml.Node) tlsOptions {
+	opts := tlsOptions{
+		caCert:             optCACert,
+		clientCert:         optClientCert,
+		clientKey:          optClientKey,
+		insecureSkipVerify: optInsecure,
+	}
+	if cfgClient == nil {
+		return opts
+	}
+	extraNode, ok := getNodeValue(cfgClient, "extra", yaml.MappingNode)
+	if !ok {
+		return opts
+	}
+	if node, ok := getNodeValue(extraNode, "ca_cert", yaml.ScalarNode); ok && opts.caCert == "" {
+		opts.caCert = node.Value
+	}
+	if node, ok := getNodeValue(extraNode, "client_cert", yaml.ScalarNode); ok && opts.clientCert == "" {
+		opts.clientCert = node.Value
+	}
+	if node, ok := getNodeValue(extraNode, "client_key", yaml.ScalarNode); ok && opts.clientKey == "" {
+		opts.clientKey = node.Value
+	}
+	if node, ok := getNodeValue(extraNode, "insecure_skip_verify", yaml.ScalarNode); ok && node.Value == "true" {
+		opts.insecureSkipVerify = true
+	}
+	return opts
+}
+
+// config returns the TLS config, nil if nothing is customized.
+func (o tlsOptions) config() (*tls.Config, error) {
+	if o.caCert == "" && o.clientCert == "" && o.clientKey == "" && !o.insecureSkipVerify {
+		return nil, nil
+	}
+	cfg := &tls.Config{}
+	if o.insecureSkipVerify {
+		logrus.Warn("TLS certificate verification is skipped")
+		cfg.InsecureSkipVerify = true
+	}
+	if o.caCert != "" {
+		pem, err := os.ReadFile(o.caCert)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, tracerr.Errorf("no certificate found in CA certificate file: %s", o.caCert)
+		}
+		cfg.RootCAs = pool
+		verboseDebug("CA certificate loaded: %s", o.caCert)
+	}
+	if o.clientCert != "" || o.clientKey != "" {
+		if o.clientCert == "" || o.clientKey == "" {
+			return nil, tracerr.New("both client certificate and client key are required for mTLS")
+		}
+		cert, err := tls.LoadX509KeyPair(o.clientCert, o.clientKey)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+		verboseDebug("client certificate loaded: %s", o.clientCert)
+	}
+	return cfg, nil
+}
+
+// getExtraHosts returns the api_base of other servers in extra.hosts of the client.
+func getExtraHosts(cfgClient *yaml.Node) ([]string, error) {
+	extraNode, ok := getNodeValue(cfgClient, "extra", yaml.MappingNode)
+	if !ok {
+		return nil, nil
+	}
+	hostsNode, ok := getNodeValue(extraNode, "hosts", yaml.SequenceNode)
+	if !ok {
+		return nil, nil
+	}
+	hosts := []string{}
+	for _, node := range hostsNode.Content {
+		host, err := expandEnv(node.Value)
+		if err != nil {
+			return nil, tracerr.Errorf("extra.hosts: %w", err)
+		}
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	verboseInfo("extra hosts found: %s", strings.Join(lo.Map(hosts, func(host string, _ int) string {
+		return redactURL(host)
+	}), ", "))
+	return hosts, nil
+}
+
+// getExtraList returns the entries of the key in extra of the client, a list or a comma separated string.
+func getExtraList(cfgClient *yaml.Node, key string) []string {
+	extraNode, ok := getNodeValue(cfgClient, "extra", yaml.MappingNode)
+	if !ok {
+		return nil
+	}
+	entries := []string{}
+	if node, ok := getNodeValue(extraNode, key, yaml.ScalarNode); ok {
+		entries = splitList(node.Value)
+	} else if node, ok := getNodeValue(extraNode, key, yaml.SequenceNode); ok {
+		for _, entry := range node.Content {
+			entries = append(entries, splitList(entry.Value)...)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	verboseInfo("extra.%s found: %s", key, strings.Join(entries, ", "))
+	return entries
+}
+
+// getProxyURL returns the proxy from --proxy, or extra.proxy of the client if any when HTTPS_PROXY is not set.
+// Nil means the proxy is taken from the environment as usual.
+func getProxyURL(cfgClient *yaml.Node) (*url.URL, error) {
+	rawURL, source := optProxy, "--proxy"
+	if rawURL == "" && cfgClient != nil && os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" {
+		if extraNode, ok := getNodeValue(cfgClient, "extra", yaml.MappingNode); ok {
+			if proxyNode, ok := getNodeValue(extraNode, "proxy", yaml.ScalarNode); ok {
+				rawURL, source = proxyNode.Value, fmt.Sprintf("extra.proxy of client %s", optClientName)
+			}
+		}
+	}
+	if rawURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, tracerr.Errorf("invalid proxy in %s: %w", source, err)
+	}
+	if !lo.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "" {
+		return nil, tracerr.Errorf("invalid proxy in %s, http, https or socks5 url expected: %s", source, redactURL(rawURL))
+	}
+	verboseInfo("proxy from %s: %s", source, redactURL(rawURL))
+	return u, nil
+}
+
+// createHTTPClient creates the http client with the api key, TLS, proxy and unix domain socket settings,
+// and returns it with the parsed api_base, nil if api_base is empty and no unix domain socket is used.
+func createHTTPClient(apiBase, apiKey string, tlsOpts tlsOptions, proxyURL *url.URL) (*http.Client, *url.URL, error) {
+	// Clone http.DefaultTransport to keep its timeouts and proxy settings,
+	// the dialer is replaced for unix domain socket below.
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := tlsOpts.config()
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	if tlsConfig != nil {
+		base.TLSClientConfig = tlsConfig
+	}
+	if proxyURL != nil {
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// Wrap it
+	wrapped := &apiKeyTransport{
+		rt:     base,
+		apiKey: apiKey,
+		header: optAuthHeader,
+		scheme: optAuthScheme,
+	}
+
+	httpClient := &http.Client{
+		Transport: wrapped,
+	}
+
+	var u *url.URL
+	if apiBase != "" {
+		parsed, err := url.Parse(apiBase)
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		u = parsed
+		// move the credentials to the transport
+		if u.User != nil {
+			wrapped.user = u.User
+			u.User = nil
+			if apiKey != "" && strings.EqualFold(optAuthHeader, "Authorization") {
+				logrus.Warn("both api_base credentials and api_key are set, api_key takes precedence")
+			}
+		}
+	}
+
+	// unix domain socket, e.g. unix:///run/ollama/ollama.sock
+	socketPath := optUnixSocket
+	if u != nil && u.Scheme == "unix" {
+		if socketPath == "" {
+			socketPath = u.Path
+		}
+		u = nil
+	}
+	if socketPath != "" {
+		verboseDebug("unix socket: %s", socketPath)
+		base.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+		if u == nil {
+			// the host is a dummy, every connection is dialed to the socket
+			u = &url.URL{Scheme: "http", Host: "localhost"}
+		}
+	}
+	return httpClient, u, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthOfAPIBase(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	tests := []struct {
+		name   string
+		extra  []string
+		args   []string
+		header string // header of the api key, if any
+		auth   string
+		key    string
+	}{
+		{name: "credentials only", auth: basic},
+		{name: "api key takes precedence", extra: []string{"api_key: token"}, auth: "Bearer token"},
+		{name: "api key of another header", extra: []string{"api_key: token"}, args: []string{"--auth-header", "X-Api-Key", "--auth-scheme", ""},
+			header: "X-Api-Key", auth: basic, key: "token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newOllamaMock(t, testModels...)
+			url := strings.Replace(mock.URL, "://", "://user:secret@", 1)
+			cfgFile := writeFile(t, "config.yaml", ollamaConfig(url, tt.extra...))
+
+			res := runMain(t, "", append([]string{"-c", cfgFile, "-vv"}, tt.args...)...)
+			if res.code != exitOK {
+				t.Fatalf("exit code %d: %s", res.code, res.stderr)
+			}
+			headers := mock.headers()
+			if len(headers) == 0 {
+				t.Fatal("no request received")
+			}
+			for _, header := range headers {
+				if got := header.Get("Authorization"); got != tt.auth {
+					t.Errorf("Authorization: %q, expected %q", got, tt.auth)
+				}
+				if tt.header != "" && header.Get(tt.header) != tt.key {
+					t.Errorf("%s: %q, expected %q", tt.header, header.Get(tt.header), tt.key)
+				}
+			}
+			if strings.Contains(res.stderr, "secret") {
+				t.Errorf("credentials logged:\n%s", res.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+)
+
+// colorModes are the modes of --color.
+var colorModes = []string{"auto", "always", "never"}
+
+// checkColorMode checks the mode of --color.
+func checkColorMode() error {
+	if optColor != "" && !lo.Contains(colorModes, optColor) {
+		return tracerr.Errorf("unknown color mode: %s", optColor)
+	}
+	return nil
+}
+
+// useColor reports whether the output to stderr, e.g. the logs, is colored by --color. In auto mode, the
+// default, a terminal is colored unless NO_COLOR is set to a non-empty value.
+func useColor() bool {
+	switch optColor {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return os.Getenv("NO_COLOR") == "" && isCharDevice(os.Stderr)
+	}
+}
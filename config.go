@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// aichatConfig is the aichat configuration read with the node of the client to sync.
+type aichatConfig struct {
+	origBody       []byte     // config file as read
+	doc            *yaml.Node // document node, kept the comments
+	defModelNode   *yaml.Node // value node of "model", nil if not found
+	defModelClient string     // client of the default model
+	defModelName   string     // name of the default model
+	clients        *yaml.Node // sequence node of "clients"
+	client         *yaml.Node // mapping node of the client of --client or the default model
+}
+
+// readAichatConfig reads the aichat configuration of --config, or discovered as aichat does,
+// and finds the client of --client, or the client of the default model if not provided.
+func readAichatConfig() (*aichatConfig, error) {
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := cfg.findClient(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return cfg, nil
+}
+
+// loadAichatConfig reads and parses the aichat configuration of --config, or discovered as aichat does.
+func loadAichatConfig() (*aichatConfig, error) {
+	if optCfgFile == "" {
+		cfgFile, err := findConfigFile()
+		if err != nil {
+			return nil, withExitCode(exitConfigError, err)
+		}
+		optCfgFile = cfgFile
+	}
+	cfgBody, err := readConfigFile(optCfgFile)
+	if err != nil {
+		return nil, withExitCode(exitConfigError, err)
+	}
+	cfg := &aichatConfig{origBody: cfgBody, doc: &yaml.Node{}}
+
+	// use yaml.Node type to unmarshal in order to keep the comment, the comments at the top of the file
+	// separated by a blank line are the head comment of the document
+	if err := yaml.Unmarshal(cfgBody, cfg.doc); err != nil {
+		return nil, withExitCode(exitConfigError, tracerr.Errorf("invalid config file (%s): %w", optCfgFile, err))
+	}
+	if len(cfg.doc.Content) == 0 {
+		return nil, withExitCode(exitConfigError, tracerr.New("empty config file"))
+	}
+	// after "---" the comments separated by a blank line are of the first key, ending with a new line
+	// which is not written back, they are moved to the document as without "---"
+	if root := cfg.doc.Content[0]; cfg.doc.HeadComment == "" && root.Kind == yaml.MappingNode && len(root.Content) > 0 &&
+		strings.HasSuffix(root.Content[0].HeadComment, "\n") {
+		cfg.doc.HeadComment = strings.TrimSuffix(root.Content[0].HeadComment, "\n")
+		root.Content[0].HeadComment = ""
+	}
+
+	// find the default client and model
+	if node, ok := getNodeValue(cfg.doc.Content[0], "model", yaml.ScalarNode); ok {
+		re := regexp.MustCompile(`^([^:]+):(.*)$`)
+		match := re.FindStringSubmatch(node.Value)
+		if len(match) > 2 {
+			cfg.defModelNode = node
+			cfg.defModelClient = strings.TrimSpace(match[1])
+			cfg.defModelName = strings.TrimSpace(match[2])
+		}
+	}
+	verboseInfo("default model found: %s:%s", cfg.defModelClient, cfg.defModelName)
+
+	// find the clients
+	cfg.clients, _ = getNodeValue(cfg.doc.Content[0], "clients", yaml.SequenceNode)
+	if cfg.clients != nil {
+		verboseInfo("clients found: %d", len(cfg.clients.Content))
+	}
+	return cfg, nil
+}
+
+// findClient finds the client of --client, or the client of the default model if not provided, or the only
+// client of type ollama if there is no default model.
+func (cfg *aichatConfig) findClient() error {
+	if cfg.clients == nil || len(cfg.clients.Content) == 0 {
+		if cfg.malformedClients() {
+			return withExitCode(exitConfigError, tracerr.Errorf("clients is not a list in config (%s)", optCfgFile))
+		}
+		return withExitCode(exitConfigError, tracerr.Errorf("no clients defined in config (%s), add one by add-client or init a new config", optCfgFile))
+	}
+	if optClientName == "" {
+		// use client in the model as default if user does not provided
+		optClientName = cfg.defModelClient
+	}
+	if optClientName == "" {
+		client, err := cfg.findOllamaClient()
+		if err != nil {
+			return withExitCode(exitConfigError, err)
+		}
+		cfg.client = client
+		return nil
+	}
+	for _, cn := range cfg.clients.Content {
+		if clientName(cn) == optClientName {
+			cfg.client = cn
+		}
+	}
+	if cfg.client == nil {
+		return withExitCode(exitConfigError, tracerr.Errorf("ollama client name (%s) not found", optClientName))
+	}
+	return nil
+}
+
+// malformedClients reports whether clients is present but not a list, an empty "clients:" is null and not malformed.
+func (cfg *aichatConfig) malformedClients() bool {
+	root := cfg.doc.Content[0]
+	node, ok := getNodeValue(root, "clients", yaml.ScalarNode)
+	return cfg.clients == nil && hasNodeKey(root, "clients") && (!ok || node.ShortTag() != "!!null")
+}
+
+// findOllamaClient returns the only client of type ollama, and takes its name as --client. A client without
+// the name is named by the type as aichat does.
+func (cfg *aichatConfig) findOllamaClient() (*yaml.Node, error) {
+	clients, names := cfg.ollamaClients()
+	switch len(clients) {
+	case 0:
+		return nil, tracerr.New("no default model and no client of type ollama, --client is required")
+	case 1:
+		optClientName = names[0]
+		verboseInfo("client of type ollama found: %s", optClientName)
+		return clients[0], nil
+	default:
+		return nil, tracerr.Errorf("several clients of type ollama, --client is required: %s", strings.Join(names, ", "))
+	}
+}
+
+// ollamaClients returns the clients of type ollama and their names.
+func (cfg *aichatConfig) ollamaClients() ([]*yaml.Node, []string) {
+	clients := []*yaml.Node{}
+	names := []string{}
+	if cfg.clients == nil {
+		return clients, names
+	}
+	for _, cn := range cfg.clients.Content {
+		if node, ok := getNodeValue(cn, "type", yaml.ScalarNode); !ok || node.Value != "ollama" {
+			continue
+		}
+		clients = append(clients, cn)
+		names = append(names, clientName(cn))
+	}
+	return clients, names
+}
+
+// clientName returns the name of the client, or the type if the client has no name as aichat does.
+func clientName(client *yaml.Node) string {
+	if node, ok := getNodeValue(client, "name", yaml.ScalarNode); ok && node.Value != "" {
+		return node.Value
+	}
+	if node, ok := getNodeValue(client, "type", yaml.ScalarNode); ok {
+		return node.Value
+	}
+	return ""
+}
+
+// marshal marshals the document of the config in the output format, the comments at the top of the file
+// are kept and so is the "---" starting the file in YAML.
+func (cfg *aichatConfig) marshal() ([]byte, error) {
+	body, err := marshalConfig(cfg.doc)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if (optFormat == "" || optFormat == "yaml") && bytes.HasPrefix(cfg.origBody, []byte("---")) {
+		body = append([]byte("---\n"), body...)
+	}
+	return body, nil
+}
+
+// write writes the config as the sync does, verified unless --no-verify, and confirmed if needed before the
+// output file is written. The references to the removed client, warned already, pass the verification.
+func (cfg *aichatConfig) write(summary syncSummary, removedClient string) error {
+	body, err := cfg.marshal()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if !optNoVerify && isConfigFormat() {
+		if err := verifyOutput(body, removedClient); err != nil {
+			return withExitCode(exitInvalid, tracerr.Errorf("output verification failed, nothing written, --no-verify to skip: %w", err))
+		}
+	}
+	return writeOutput(strings.TrimSpace(string(body)), summary)
+}
+
+// createClientSource creates the model source of the client.
+func createClientSource(client *yaml.Node) (modelSource, error) {
+	apiBase, apiKey, err := getAPIBaseKey(client)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	tlsOpts := getTLSOptions(client)
+	proxyURL, err := getProxyURL(client)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	hosts, err := getExtraHosts(client)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	src, err := createModelSource(getSourceName(client), apiBase, hosts, apiKey, tlsOpts, proxyURL)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return src, nil
+}
+
+// getAPIBaseKey returns api_base and api_key of the client, taken in the order of --api-base / --api-key,
+// <PREFIX>_API_BASE / <PREFIX>_API_KEY environment variables and the client settings. An empty api_base is
+// the OLLAMA_HOST environment variable or the ollama default.
+func getAPIBaseKey(client *yaml.Node) (string, string, error) {
+	apiKey := ""
+	if optAPIKey != "" {
+		apiKey = optAPIKey
+		verboseDebug("api_key overridden from command line")
+	} else if v, ok := lookupPrefixedEnv("API_KEY"); ok {
+		apiKey = v
+		verboseDebug("api_key found in environment")
+	} else if node, ok := getNodeValue(client, "api_key", yaml.ScalarNode); ok {
+		// expand for connecting only, the placeholder in the node is kept for output
+		v, err := expandEnv(node.Value)
+		if err != nil {
+			return "", "", tracerr.Errorf("api_key: %w", err)
+		}
+		apiKey = v
+		verboseDebug("api_key found")
+	}
+
+	apiBase := ""
+	if optAPIBase != "" {
+		apiBase = optAPIBase
+		verboseInfo("api_base overridden from command line: %s", redactURL(apiBase))
+	} else if v, ok := lookupPrefixedEnv("API_BASE"); ok {
+		apiBase = v
+		verboseInfo("api_base found in environment: %s", redactURL(apiBase))
+	} else if node, ok := getNodeValue(client, "api_base", yaml.ScalarNode); ok {
+		v, err := expandEnv(node.Value)
+		if err != nil {
+			return "", "", tracerr.Errorf("api_base: %w", err)
+		}
+		apiBase = v
+		verboseInfo("api_base found: %s", redactURL(apiBase))
+	} else {
+		verboseInfo("api_base not found, use default")
+	}
+	return apiBase, apiKey, nil
+}
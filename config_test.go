@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigWithoutClients(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "no clients", config: "model: ollama:llama3\n", err: "no clients defined in config"},
+		{name: "empty clients", config: "model: ollama:llama3\nclients:\n", err: "no clients defined in config"},
+		{name: "empty list", config: "model: ollama:llama3\nclients: []\n", err: "no clients defined in config"},
+		{name: "not a list", config: "model: ollama:llama3\nclients: ollama\n", err: "clients is not a list in config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runMain(t, "", "-c", writeFile(t, "config.yaml", tt.config))
+			if res.code != exitConfigError || !strings.Contains(res.stderr, tt.err) {
+				t.Errorf("exit code %d: %s", res.code, res.stderr)
+			}
+			if res.stdout != "" {
+				t.Errorf("stdout: %q", res.stdout)
+			}
+		})
+	}
+}
+
+func TestLeadingCommentsKept(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	header := `# aichat config
+# generated from the template, see README
+#
+#   render-template | aichatconf -c - | install-config
+`
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{name: "separated by a blank line", prefix: header + "\n"},
+		{name: "attached to the first key", prefix: header},
+		{name: "after the document start", prefix: "---\n" + header + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runMain(t, "", "-c", writeFile(t, "config.yaml", tt.prefix+ollamaConfig(mock.URL)))
+			if res.code != exitOK {
+				t.Fatalf("exit code %d: %s", res.code, res.stderr)
+			}
+			if !strings.HasPrefix(res.stdout, tt.prefix) {
+				t.Errorf("leading comments changed:\n%s", res.stdout)
+			}
+		})
+	}
+}
+
+func TestClientWithoutName(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := strings.Replace(ollamaConfig(mock.URL), "    name: ollama\n", "", 1)
+	cfgFile := writeFile(t, "config.yaml", config)
+
+	// the client is named by the type, as by aichat
+	for _, args := range [][]string{{}, {"--client", "ollama"}, {"--all-ollama"}} {
+		res := runMain(t, "", append([]string{"-c", cfgFile}, args...)...)
+		if res.code != exitOK {
+			t.Fatalf("%v: exit code %d: %s", args, res.code, res.stderr)
+		}
+		if n := strings.Count(res.stdout, "- name: "); n != len(testModels) {
+			t.Errorf("%v: models of the output: %d\n%s", args, n, res.stdout)
+		}
+	}
+	res := runMain(t, "", "add-client", "-c", cfgFile, "--client", "ollama", "--type", "ollama", "--api-base", mock.URL)
+	if res.code == exitOK || !strings.Contains(res.stderr, "client already exists: ollama") {
+		t.Errorf("duplicate client added, exit code %d: %s", res.code, res.stderr)
+	}
+	res = runMain(t, "", "remove-client", "-c", cfgFile, "--client", "ollama", "--force")
+	if res.code != exitOK || !strings.Contains(res.stdout, "clients: []") {
+		t.Errorf("client not removed, exit code %d: %s\n%s", res.code, res.stderr, res.stdout)
+	}
+}
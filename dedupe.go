@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+)
+
+// dedupeByDigest keeps one model of the models of the same digest, the one of the tag matching
+// --prefer-tag first, and then the shortest name.
+func dedupeByDigest(models []ollamaModel) ([]ollamaModel, error) {
+	if _, ok := modelSrc.(detailedSource); !ok {
+		return nil, tracerr.Errorf("dedupe by digest is not supported by source: %s", modelSrc.name())
+	}
+	aliases := map[string][]string{}
+	for _, model := range models {
+		if model.details.digest != "" {
+			aliases[model.details.digest] = append(aliases[model.details.digest], model.name)
+		}
+	}
+	dropped := []string{}
+	for _, names := range aliases {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Slice(names, func(a, b int) bool {
+			aPreferred, bPreferred := isPreferredTag(names[a]), isPreferredTag(names[b])
+			if aPreferred != bPreferred {
+				return aPreferred
+			}
+			if len(names[a]) != len(names[b]) {
+				return len(names[a]) < len(names[b])
+			}
+			return names[a] < names[b]
+		})
+		verboseInfo("collapse models of the same digest: %s -> %s", strings.Join(names[1:], ", "), names[0])
+		dropped = append(dropped, names[1:]...)
+	}
+	return lo.Filter(models, func(model ollamaModel, _ int) bool { return !lo.Contains(dropped, model.name) }), nil
+}
+
+func isPreferredTag(model string) bool {
+	if optPreferTag == "" {
+		return false
+	}
+	_, tag, _ := strings.Cut(model, ":")
+	matched, _ := matchModelName("glob", optPreferTag, tag)
+	return matched
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// deprecatedKey is an old or misspelt key of the config, renamed to the replacement by --fix-deprecated.
+// A key which is not a plain rename is only warned while the replacement is missing.
+type deprecatedKey struct {
+	key         string
+	replacement string
+	rename      bool
+	note        string
+}
+
+// deprecatedKeys are the known deprecated keys of the top level of the config.
+var deprecatedKeys = []deprecatedKey{
+	{key: "reg_reranker_model", replacement: "rag_reranker_model", rename: true, note: "misspelt"},
+	{key: "function_calling", replacement: "use_tools", note: "function_calling only turns the tools on or off, the tools are chosen by use_tools"},
+}
+
+// deprecatedFinding is an occurrence of a deprecated key in the config.
+type deprecatedFinding struct {
+	deprecatedKey
+	node *yaml.Node // key node
+	line int        // line in the file
+}
+
+func (f deprecatedFinding) String() string {
+	if !f.rename {
+		return fmt.Sprintf("line %d: %s without %s, %s", f.line, f.key, f.replacement, f.note)
+	}
+	return fmt.Sprintf("line %d: deprecated key %s, use %s (%s)", f.line, f.key, f.replacement, f.note)
+}
+
+// findDeprecatedKeys returns the deprecated keys in the top level of the config in the order of the file.
+func findDeprecatedKeys(cfg *aichatConfig) []deprecatedFinding {
+	findings := []deprecatedFinding{}
+	root := cfg.doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		for _, deprecated := range deprecatedKeys {
+			if root.Content[i].Value == deprecated.key && (deprecated.rename || !hasNodeKey(root, deprecated.replacement)) {
+				findings = append(findings, deprecatedFinding{deprecated, root.Content[i], root.Content[i].Line})
+			}
+		}
+	}
+	return findings
+}
+
+// fixDeprecatedKeys warns about the deprecated keys of the config, and renames them in place with --fix-deprecated,
+// the value and the comments are kept. A key is not renamed if the replacement is present already.
+func fixDeprecatedKeys(cfg *aichatConfig) {
+	for _, finding := range findDeprecatedKeys(cfg) {
+		if !optFixDeprecated || !finding.rename {
+			logrus.Warnf("%s", finding)
+			continue
+		}
+		if hasNodeKey(cfg.doc.Content[0], finding.replacement) {
+			logrus.Warnf("%s, not renamed as %s is present", finding, finding.replacement)
+			continue
+		}
+		finding.node.Value = finding.replacement
+		verboseInfo("rename deprecated key: %s -> %s", finding.key, finding.replacement)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// modelChange is a change of a model made by the sync, the records of --diff-only.
+type modelChange struct {
+	Action string         `json:"action"` // add, remove or update
+	Model  string         `json:"model"`
+	Fields map[string]any `json:"fields,omitempty"` // the fields added or changed, null for the removed ones
+}
+
+// snapshotModels returns the fields of the models by the name.
+func snapshotModels(models *yaml.Node) map[string]map[string]any {
+	snapshot := map[string]map[string]any{}
+	for _, cfgModel := range models.Content {
+		cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+		if !ok {
+			continue
+		}
+		fields := map[string]any{}
+		if err := cfgModel.Decode(&fields); err != nil {
+			continue
+		}
+		delete(fields, "name")
+		snapshot[cfgModelName.Value] = fields
+	}
+	return snapshot
+}
+
+// diffModels returns the changes between the snapshots sorted by the model and the action.
+func diffModels(before, after map[string]map[string]any) []modelChange {
+	changes := []modelChange{}
+	for model, fields := range after {
+		old, ok := before[model]
+		if !ok {
+			changes = append(changes, modelChange{Action: "add", Model: model, Fields: fields})
+			continue
+		}
+		changed := map[string]any{}
+		for key, value := range fields {
+			if oldValue, ok := old[key]; !ok || !reflect.DeepEqual(oldValue, value) {
+				changed[key] = value
+			}
+		}
+		for key := range old {
+			if _, ok := fields[key]; !ok {
+				changed[key] = nil
+			}
+		}
+		if len(changed) > 0 {
+			changes = append(changes, modelChange{Action: "update", Model: model, Fields: changed})
+		}
+	}
+	for model := range before {
+		if _, ok := after[model]; !ok {
+			changes = append(changes, modelChange{Action: "remove", Model: model})
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool {
+		if changes[a].Model != changes[b].Model {
+			return changes[a].Model < changes[b].Model
+		}
+		return changes[a].Action < changes[b].Action
+	})
+	return changes
+}
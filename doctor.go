@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	olmapi "github.com/ollama/ollama/api"
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// doctor checks the config and the server of the client, each check is printed as passed, failed or skipped,
+// and an error is returned if any check failed.
+func doctor() error {
+	failed := 0
+	report := func(err error, format string, args ...any) {
+		status := "PASS"
+		if err != nil {
+			status = "FAIL"
+			failed++
+		}
+		message := fmt.Sprintf(format, args...)
+		if err != nil {
+			message = fmt.Sprintf("%s: %v", message, err)
+		}
+		fmt.Printf("%s  %s\n", status, message)
+	}
+	skip := func(format string, args ...any) {
+		fmt.Printf("SKIP  %s\n", fmt.Sprintf(format, args...))
+	}
+	warn := func(format string, args ...any) {
+		fmt.Printf("WARN  %s\n", fmt.Sprintf(format, args...))
+	}
+	result := func() error {
+		if failed > 0 {
+			return tracerr.Errorf("%d checks failed", failed)
+		}
+		return nil
+	}
+
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		report(err, "config parsed")
+		return result()
+	}
+	report(nil, "config parsed: %s", optCfgFile)
+	for _, finding := range findDeprecatedKeys(cfg) {
+		warn("%s", finding)
+	}
+	err = cfg.findClient()
+	report(err, "client found: %s", optClientName)
+	if err != nil {
+		return result()
+	}
+
+	apiBase, apiKey, err := getAPIBaseKey(cfg.client)
+	if err == nil {
+		modelSrc, err = createClientSource(cfg.client)
+	}
+	if apiBase == "" {
+		apiBase = "default"
+	}
+	if err != nil {
+		report(err, "server reachable: %s", redactURL(apiBase))
+		return result()
+	}
+	start := time.Now()
+	models, err := modelSrc.listModels()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil && isAuthError(err) {
+		// the server answered, the api key is rejected
+		report(nil, "server reachable: %s in %s", redactURL(apiBase), elapsed)
+		report(err, "api_key accepted")
+		return result()
+	}
+	if err != nil {
+		report(err, "server reachable: %s", redactURL(apiBase))
+		return result()
+	}
+	report(nil, "server reachable: %s, %d models in %s", redactURL(apiBase), len(models), elapsed)
+	if apiKey == "" {
+		skip("api_key accepted: no api_key")
+	} else {
+		report(nil, "api_key accepted")
+	}
+
+	// the models kept regardless of the server are not checked
+	cfgModels, ok := getNodeValue(cfg.client, "models", yaml.SequenceNode)
+	if !ok {
+		cfgModels = &yaml.Node{Kind: yaml.SequenceNode}
+	}
+	names := modelNames(models)
+	missing := []string{}
+	for _, cfgModel := range cfgModels.Content {
+		cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+		if !ok || isKeptModel(cfgModel, cfgModelName.Value, nil) {
+			continue
+		}
+		if name := patchedModelName(cfgModel); !lo.Contains(names, cfgModelName.Value) && !lo.Contains(names, name) {
+			missing = append(missing, cfgModelName.Value)
+		}
+	}
+	if len(missing) > 0 {
+		report(errors.New(strings.Join(missing, ", ")), "models on the server")
+	} else {
+		report(nil, "models on the server: %d", len(cfgModels.Content))
+	}
+
+	if cfg.defModelNode == nil {
+		skip("default model found: no model")
+	} else {
+		report(checkModelEntry(cfg, cfg.defModelNode.Value), "default model found: %s", cfg.defModelNode.Value)
+	}
+	if node, ok := getNodeValue(cfg.doc.Content[0], "rag_embedding_model", yaml.ScalarNode); ok && node.Tag != "!!null" {
+		report(checkModelEntry(cfg, node.Value), "rag_embedding_model found: %s", node.Value)
+	} else {
+		skip("rag_embedding_model found: no rag_embedding_model")
+	}
+	return result()
+}
+
+// checkModelEntry checks the model, client:model, is a client of the config, and a model of the client
+// if the client lists the models.
+func checkModelEntry(cfg *aichatConfig, value string) error {
+	clientRef, modelName, ok := strings.Cut(value, ":")
+	if !ok {
+		return tracerr.New("client:model is expected")
+	}
+	for _, cn := range cfg.clients.Content {
+		if clientName(cn) != clientRef {
+			continue
+		}
+		models, ok := getNodeValue(cn, "models", yaml.SequenceNode)
+		if !ok || len(models.Content) == 0 || findModelNode(models, modelName) != nil {
+			return nil
+		}
+		return tracerr.Errorf("model not found in client %s", clientRef)
+	}
+	return tracerr.Errorf("client not found: %s", clientRef)
+}
+
+// isAuthError reports whether the error is the rejection of the api key by the server.
+func isAuthError(err error) bool {
+	var statusErr olmapi.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+	}
+	// the other sources report the status of the response
+	return strings.Contains(err.Error(), "401 Unauthorized") || strings.Contains(err.Error(), "403 Forbidden")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/ztrue/tracerr"
+)
+
+// The exit codes of aichatconf, for the scripts telling the failures apart.
+const (
+	exitOK          = 0 // synced, or nothing to change
+	exitError       = 1 // any other error, e.g. the usage
+	exitConfigError = 2 // the config cannot be found, read or parsed, or has no client to sync
+	exitConnError   = 3 // the server of the client cannot be listed or queried
+	exitInvalid     = 4 // the config or the output is invalid, by validate, the validation or the verification of the output
+	exitOutOfDate   = 5 // --check finds the config out of date
+)
+
+// exitCodeError is an error carrying the exit code of aichatconf, it is a tracerr.Error of the stack trace of
+// the marked error.
+type exitCodeError struct {
+	err  tracerr.Error
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) StackTrace() []tracerr.Frame {
+	return e.err.StackTrace()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err.Unwrap()
+}
+
+// withExitCode marks the error with the exit code, nil stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{err: tracerr.Wrap(err), code: code}
+}
+
+// exitCode returns the exit code of the error, the first one marked in the chain, or exitError.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var codeErr *exitCodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code
+	}
+	return exitError
+}
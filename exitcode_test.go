@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExitCodes(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := ollamaConfig(mock.URL)
+	synced := runMain(t, "", "-c", writeFile(t, "config.yaml", config))
+	if synced.code != exitOK {
+		t.Fatalf("exit code %d: %s", synced.code, synced.stderr)
+	}
+	closed := httptest.NewServer(nil)
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		command string
+		config  string
+		args    []string
+		code    int
+	}{
+		{name: "synced", config: config, code: exitOK},
+		{name: "unknown option", config: config, args: []string{"--no-such-option"}, code: exitError},
+		{name: "invalid yaml", config: "clients: [\n", code: exitConfigError},
+		{name: "client not found", config: config, args: []string{"--client", "nope"}, code: exitConfigError},
+		{name: "server not reachable", config: ollamaConfig(closed.URL), code: exitConnError},
+		{name: "invalid by validate", command: "validate", config: config + "    models:\n      - type: chat\n", code: exitInvalid},
+		{name: "invalid model of another client", config: config + "  - type: openai\n    name: remote\n    models:\n      - name: m\n        type: bogus\n",
+			code: exitInvalid},
+		{name: "out of date", config: config, args: []string{"--check"}, code: exitOutOfDate},
+		{name: "up to date", config: synced.stdout, args: []string{"--check"}, code: exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-c", writeFile(t, "config.yaml", tt.config)}, tt.args...)
+			if tt.command != "" {
+				args = append([]string{tt.command}, args...)
+			}
+			if res := runMain(t, "", args...); res.code != tt.code {
+				t.Errorf("exit code %d, expected %d: %s", res.code, tt.code, res.stderr)
+			}
+		})
+	}
+
+	if res := runMain(t, "", "-c", "/nonexistent/config.yaml"); res.code != exitConfigError {
+		t.Errorf("missing config, exit code %d: %s", res.code, res.stderr)
+	}
+}
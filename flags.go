@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// globalFlags are the flags shared by the commands, e.g. the config, the client and its connection, and the logging.
+func globalFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "config",
+			Aliases:     []string{"c"},
+			Usage:       "config file of aichat, use - to read from stdin, default is discovered as aichat does",
+			Destination: &optCfgFile,
+		},
+		&cli.StringFlag{
+			Name:        "client",
+			Aliases:     []string{"n"},
+			Usage:       "client name, default the client of the default model or the only client of type ollama",
+			Destination: &optClientName,
+		},
+		&cli.StringFlag{
+			Name:        "source",
+			Usage:       "model source: ollama, openai-compatible, lmstudio or llama-server, default by the type and name of the client",
+			Destination: &optSource,
+		},
+		&cli.StringFlag{
+			Name:        "models-file",
+			Usage:       "models dump file of ollama made by dump-models, instead of querying ollama",
+			TakesFile:   true,
+			Destination: &optModelsFile,
+		},
+		&cli.StringFlag{
+			Name:        "context-key-prefix",
+			Usage:       "preferred prefix of the context length key in the model info, e.g. llama, default the architecture of the model",
+			Destination: &optCtxKeyPrefix,
+		},
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Usage:       "output file, default is stdout",
+			Destination: &optOutFile,
+		},
+		&cli.IntFlag{
+			Name:        "indent",
+			Value:       4,
+			Usage:       "indentation of the yaml output in spaces",
+			Destination: &optIndent,
+		},
+		&cli.StringFlag{
+			Name:        "api-base",
+			Usage:       "api_base of ollama for connecting only, overrides the client setting and environment",
+			Destination: &optAPIBase,
+		},
+		&cli.StringFlag{
+			Name:        "api-key",
+			Usage:       "api_key of ollama for connecting only, overrides the client setting and environment",
+			Destination: &optAPIKey,
+		},
+		&cli.StringFlag{
+			Name:        "env-prefix",
+			Value:       "AICHATCONF",
+			Usage:       "prefix of environment variables <PREFIX>_API_BASE and <PREFIX>_API_KEY overriding the client settings",
+			Destination: &optEnvPrefix,
+		},
+		&cli.StringFlag{
+			Name:        "auth-header",
+			Value:       "Authorization",
+			Usage:       "header name to send the api key",
+			Destination: &optAuthHeader,
+		},
+		&cli.StringFlag{
+			Name:        "auth-scheme",
+			Value:       "Bearer",
+			Usage:       "scheme prepended to the api key in the auth header, empty to send the raw api key",
+			Destination: &optAuthScheme,
+		},
+		&cli.StringFlag{
+			Name:        "unix-socket",
+			Usage:       "unix domain socket of ollama, overrides the host of api_base",
+			Destination: &optUnixSocket,
+		},
+		&cli.StringFlag{
+			Name:        "ca-cert",
+			Usage:       "CA certificate PEM file to verify ollama, overrides extra.ca_cert of the client",
+			TakesFile:   true,
+			Destination: &optCACert,
+		},
+		&cli.BoolFlag{
+			Name:        "insecure-skip-verify",
+			Usage:       "skip TLS certificate verification of ollama, same as extra.insecure_skip_verify of the client",
+			Destination: &optInsecure,
+		},
+		&cli.StringFlag{
+			Name:        "client-cert",
+			Usage:       "client certificate PEM file for mTLS, overrides extra.client_cert of the client",
+			TakesFile:   true,
+			Destination: &optClientCert,
+		},
+		&cli.StringFlag{
+			Name:        "client-key",
+			Usage:       "client key PEM file for mTLS, overrides extra.client_key of the client",
+			TakesFile:   true,
+			Destination: &optClientKey,
+		},
+		&cli.StringFlag{
+			Name:        "proxy",
+			Usage:       "proxy of ollama (http, https or socks5), overrides HTTPS_PROXY and extra.proxy of the client",
+			Destination: &optProxy,
+		},
+		&cli.StringFlag{
+			Name:        "cache-dir",
+			Usage:       "directory of the cache of ollama model info, default $XDG_CACHE_HOME/aichatconf",
+			Destination: &optCacheDir,
+		},
+		&cli.BoolFlag{
+			Name:        "no-cache",
+			Usage:       "do not cache ollama model info",
+			Destination: &optNoCache,
+		},
+		&cli.BoolFlag{
+			Name:        "refresh-cache",
+			Usage:       "re-fetch the info of all ollama models into the cache",
+			Destination: &optRefreshCache,
+		},
+		&cli.BoolFlag{
+			Name:        "quiet",
+			Aliases:     []string{"q"},
+			Value:       false,
+			Usage:       "suppress all output but the errors, same as --log-level error",
+			Destination: &optQuiet,
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "log the actions on the models with -v, and the decisions and the requests with -vv",
+			Config:  cli.BoolConfig{Count: &optVerbose},
+		},
+		&cli.StringFlag{
+			Name:        "log-format",
+			Value:       "text",
+			Usage:       "log format: text or json",
+			Destination: &optLogFormat,
+		},
+		&cli.StringFlag{
+			Name:        "color",
+			Value:       "auto",
+			Usage:       "color of the logs: auto for a terminal unless NO_COLOR is set, always or never",
+			Destination: &optColor,
+		},
+		&cli.StringFlag{
+			Name:        "log-level",
+			Value:       "info",
+			Usage:       "log level: trace, debug, info, warn or error",
+			Destination: &optLogLevel,
+		},
+		&cli.BoolFlag{
+			Name:        "debug",
+			Aliases:     []string{"d"},
+			Required:    false,
+			Usage:       "enable debug mode, same as --log-level trace with the source of the errors",
+			Destination: &optDebug,
+		},
+	}
+}
+
+// syncFlags are the flags of the sync, new instances on every call as the root command takes them too.
+func syncFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:        "model",
+			Aliases:     []string{"m"},
+			Usage:       "default model",
+			Destination: &optDefModel,
+		},
+		&cli.StringFlag{
+			Name:        "auto-default",
+			Usage:       "choose the default model by the strategy: largest-context, newest or largest",
+			Destination: &optAutoDefault,
+		},
+		&cli.BoolFlag{
+			Name:        "model-exact",
+			Usage:       "match the default model by the full name including the tag",
+			Destination: &optModelExact,
+		},
+		&cli.BoolFlag{
+			Name:        "no-fix-default",
+			Usage:       "keep the default model of the client even if it is removed, instead of the first chat model",
+			Destination: &optNoFixDefault,
+		},
+		&cli.StringFlag{
+			Name:        "exclude",
+			Aliases:     []string{"e"},
+			Usage:       "models exclude, split by comma",
+			Destination: &optExclude,
+		},
+		&cli.StringFlag{
+			Name:        "include",
+			Usage:       "models include, split by comma, the others are excluded",
+			Destination: &optInclude,
+		},
+		&cli.StringFlag{
+			Name:        "exclude-mode",
+			Value:       "substring",
+			Usage:       "models exclude matching mode: substring, exact or glob",
+			Destination: &optExclMode,
+		},
+		&cli.BoolFlag{
+			Name:        "dedupe-by-digest",
+			Usage:       "keep one model of the models of the same digest, the shortest name unless --prefer-tag",
+			Destination: &optDedupe,
+		},
+		&cli.StringFlag{
+			Name:        "prefer-tag",
+			Usage:       "glob pattern of the tag preferred by --dedupe-by-digest, e.g. *instruct*",
+			Destination: &optPreferTag,
+		},
+		&cli.StringFlag{
+			Name:        "keep",
+			Usage:       "models always kept regardless of ollama, split by comma, glob pattern supported",
+			Destination: &optKeep,
+		},
+		&cli.StringFlag{
+			Name:        "normalize-latest",
+			Usage:       "normalize the :latest tag of models: strip (llama3:latest -> llama3) or add (llama3 -> llama3:latest)",
+			Destination: &optNormLatest,
+		},
+		&cli.BoolFlag{
+			Name:        "strip-latest",
+			Usage:       "strip the :latest tag of models, same as --normalize-latest strip",
+			Destination: &optStripLatest,
+		},
+		&cli.IntFlag{
+			Name:        "min-context",
+			Usage:       "exclude models with context length below the value",
+			Destination: &optMinCtx,
+		},
+		&cli.IntFlag{
+			Name:        "max-context",
+			Usage:       "cap the detected context length of the models at the value, e.g. for the memory of the host",
+			Destination: &optMaxCtx,
+		},
+		&cli.BoolFlag{
+			Name:        "min-context-strict",
+			Usage:       "also exclude models with unknown context length when --min-context is set",
+			Destination: &optMinCtxStr,
+		},
+		&cli.BoolFlag{
+			Name:        "emit-type",
+			Usage:       "emit type: chat for non-embedding models",
+			Destination: &optEmitType,
+		},
+		&cli.BoolFlag{
+			Name:        "no-remove",
+			Usage:       "do not remove obsolete models, only add new ones",
+			Destination: &optNoRemove,
+		},
+		&cli.BoolFlag{
+			Name:        "interactive",
+			Aliases:     []string{"i"},
+			Usage:       "select the models to add and remove by a checklist, skipped without a terminal",
+			Destination: &optInteractive,
+		},
+		&cli.BoolFlag{
+			Name:        "prune-clients",
+			Usage:       "remove the client when it is left without models",
+			Destination: &optPruneClients,
+		},
+		&cli.BoolFlag{
+			Name:        "no-add",
+			Usage:       "do not add new models, only remove obsolete ones",
+			Destination: &optNoAdd,
+		},
+		&cli.StringFlag{
+			Name:        "rag-embedding-model",
+			Usage:       "set rag_embedding_model to the model of the client",
+			Destination: &optRagEmbModel,
+		},
+		&cli.BoolFlag{
+			Name:        "auto-rag-embedding",
+			Usage:       "set rag_embedding_model to the first embedding model of the client when it is missing or stale",
+			Destination: &optAutoRagEmb,
+		},
+		&cli.StringFlag{
+			Name:        "rag-reranker-model",
+			Usage:       "set rag_reranker_model to the model of the client",
+			Destination: &optRagRerankModel,
+		},
+		&cli.BoolFlag{
+			Name:        "auto-rag-reranker",
+			Usage:       "set rag_reranker_model to the first reranker model of the client when it is missing or stale",
+			Destination: &optAutoRagRerank,
+		},
+		&cli.StringSliceFlag{
+			Name:        "cap-field",
+			Usage:       "field of the capability of ollama, capability=field, e.g. vision=supports_vision, an empty field to skip the capability. Repeatable",
+			Destination: &optCapFields,
+		},
+		&cli.StringFlag{
+			Name:        "cap-fields-file",
+			Aliases:     []string{"capability-map"},
+			Usage:       "YAML file of the map of the capabilities of ollama to the fields, applied before --cap-field",
+			Destination: &optCapFieldsFile,
+		},
+		&cli.StringFlag{
+			Name:        "patch-num-ctx",
+			Usage:       "patch num_ctx of ollama into the chat models, a number capped by the context length of the model, or max for the context length",
+			Destination: &optPatchNumCtx,
+		},
+		&cli.StringSliceFlag{
+			Name:        "alias",
+			Usage:       "name of a model in the config, alias=model, e.g. work-coder=qwen2.5-coder:32b, the model on the server is requested by the patch of the model, repeatable",
+			Destination: &optAliases,
+		},
+		&cli.BoolFlag{
+			Name:        "strip-tag",
+			Usage:       "sync the models under the names without the tags, e.g. llama3 for llama3:8b-instruct-q4_0, the most recent of the same name is kept",
+			Destination: &optStripTag,
+		},
+		&cli.StringFlag{
+			Name:        "alias-file",
+			Usage:       "YAML file of the aliases to the models, overridden by --alias",
+			TakesFile:   true,
+			Destination: &optAliasFile,
+		},
+		&cli.StringFlag{
+			Name:        "patch",
+			Usage:       "patch of the new models as a JSON or YAML mapping, e.g. '{\"chat_completions\": {\".*\": {\"body\": {\"stream\": false}}}}'",
+			Destination: &optPatch,
+		},
+		&cli.StringSliceFlag{
+			Name:        "set",
+			Usage:       "set a scalar key at the top level of the config, key=value, e.g. stream=false, written by the type of the key in aichat, repeatable",
+			Destination: &optSet,
+		},
+		&cli.StringFlag{
+			Name:        "set-global-temperature",
+			Usage:       "set the temperature of the top level of the config, the models of the same temperature omit it, same as --set temperature=",
+			Destination: &optGlobalTemp,
+		},
+		&cli.BoolFlag{
+			Name:        "fix-deprecated",
+			Usage:       "rename the deprecated and misspelt keys of the config, e.g. reg_reranker_model, they are warned otherwise",
+			Destination: &optFixDeprecated,
+		},
+		&cli.BoolFlag{
+			Name:        "use-sync-url",
+			Usage:       "fill the parameters unknown by the server, e.g. capabilities, from the models registry of sync_models_url of aichat",
+			Destination: &optUseSyncURL,
+		},
+		&cli.BoolFlag{
+			Name:        "all-params",
+			Usage:       "write all parameters of new models, num_ctx as max_input_tokens and the others as the options in the patch",
+			Destination: &optAllParams,
+		},
+		&cli.BoolFlag{
+			Name:        "skip-default-params",
+			Usage:       "do not emit temperature and top_p equal to the ollama defaults (0.8 and 0.9)",
+			Destination: &optSkipDefParams,
+		},
+		&cli.StringFlag{
+			Name:        "reranker",
+			Usage:       "models taken as rerankers, comma separated glob patterns, when not detected",
+			Destination: &optReranker,
+		},
+		&cli.IntFlag{
+			Name:        "embedding-chunk-size",
+			Usage:       "default chunk size of embedding models, default min(1000, context length / 2)",
+			Destination: &optEmbChunk,
+		},
+		&cli.IntFlag{
+			Name:        "embedding-batch-size",
+			Value:       100,
+			Usage:       "max batch size of embedding models, 0 to leave it unset",
+			Destination: &optEmbBatch,
+		},
+		&cli.IntFlag{
+			Name:        "embedding-max-tokens",
+			Usage:       "max tokens per chunk of embedding models, default the context length",
+			Destination: &optEmbMaxTokens,
+		},
+		&cli.StringFlag{
+			Name:        "defaults",
+			Usage:       "YAML file of model fields applied to new models when not detected",
+			TakesFile:   true,
+			Destination: &optDefaults,
+		},
+		&cli.BoolFlag{
+			Name:        "apply-defaults-to-existing",
+			Usage:       "also apply the defaults to existing models for fields not set",
+			Destination: &optDefExist,
+		},
+		&cli.StringFlag{
+			Name:        "overrides",
+			Usage:       "YAML file of rules setting fields of models matching the name pattern",
+			TakesFile:   true,
+			Destination: &optOverrides,
+		},
+		&cli.BoolFlag{
+			Name:        "check",
+			Usage:       "exit with an error if the config is out of date, nothing is written",
+			Destination: &optCheck,
+		},
+		&cli.BoolFlag{
+			Name:        "diff-only",
+			Usage:       "print the changes of the models as JSON records, nothing is written",
+			Destination: &optDiffOnly,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "yaml",
+			Usage:       "output format: yaml, json, models-yaml for the models of the client in the format of sync_models_url of aichat, names or added-names for the names of the models or the added ones, comments are kept in yaml only",
+			Destination: &optFormat,
+		},
+		&cli.StringFlag{
+			Name:        "prune-mode",
+			Value:       "delete",
+			Usage:       "what to do with the removed models: delete, or comment to comment them out and restore them when back on the server",
+			Destination: &optPruneMode,
+		},
+		&cli.StringFlag{
+			Name:        "sort",
+			Value:       "name",
+			Usage:       "order of the models: name, or size for the largest file first",
+			Destination: &optSort,
+		},
+		&cli.BoolFlag{
+			Name:        "all-ollama",
+			Usage:       "sync all the clients of type ollama one after another, --client is ignored",
+			Destination: &optAllOllama,
+		},
+		&cli.BoolFlag{
+			Name:        "running-only",
+			Usage:       "sync the models loaded in memory (ollama ps) instead of all the models of ollama",
+			Destination: &optRunningOnly,
+		},
+		&cli.BoolFlag{
+			Name:        "annotate",
+			Usage:       "comment the name of each model with the parameter size, the quantization and the file size, --annotate=false removes the comments",
+			Destination: &optAnnotate,
+			Action: func(context.Context, *cli.Command, bool) error {
+				optAnnotateSet = true
+				return nil
+			},
+		},
+		&cli.BoolFlag{
+			Name:        "stamp",
+			Usage:       "stamp the models of the client with a comment of the version and the time of the last sync changing them",
+			Destination: &optStamp,
+		},
+		&cli.StringFlag{
+			Name:        "style",
+			Value:       "block",
+			Usage:       "style of the yaml output: block, flow for a model per line, or preserve for the style of the config",
+			Destination: &optStyle,
+		},
+		&cli.BoolFlag{
+			Name:        "no-validate",
+			Usage:       "write the result without validating it against the aichat config",
+			Destination: &optNoValidate,
+		},
+		&cli.BoolFlag{
+			Name:        "watch",
+			Usage:       "sync again on --interval until interrupted, the output file is only written when changed",
+			Destination: &optWatch,
+		},
+		&cli.DurationFlag{
+			Name:        "interval",
+			Value:       60 * time.Second,
+			Usage:       "interval of --watch, e.g. 5m",
+			Destination: &optInterval,
+		},
+		&cli.StringFlag{
+			Name:        "on-change",
+			Usage:       "command run by the shell when the output file is written with changes, the changes are in AICHATCONF_ADDED, AICHATCONF_REMOVED and AICHATCONF_CONFIG",
+			Destination: &optOnChange,
+		},
+		&cli.BoolFlag{
+			Name:        "on-change-strict",
+			Usage:       "fail the sync when the command of --on-change fails",
+			Destination: &optOnChangeStrict,
+		},
+		&cli.BoolFlag{
+			Name:        "keep-on-error",
+			Usage:       "keep the config unchanged and exit normally when ollama is unreachable",
+			Destination: &optKeepOnErr,
+		},
+	}, writeFlags()...)
+}
+
+// writeFlags are the flags of writing the config, shared by the sync and the commands editing the config.
+func writeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "confirm",
+			Usage:       "confirm the changes before writing the output file, default when the output file exists and stdout is a terminal",
+			Destination: &optConfirm,
+		},
+		&cli.BoolFlag{
+			Name:        "yes",
+			Aliases:     []string{"y"},
+			Usage:       "write the output file without confirmation",
+			Destination: &optYes,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "print the output to stdout instead of writing the output file",
+			Destination: &optDryRun,
+		},
+		&cli.BoolFlag{
+			Name:        "no-verify",
+			Usage:       "write the result without verifying the output decodes strictly to the aichat config",
+			Destination: &optNoVerify,
+		},
+	}
+}
+
+// localFlags marks the flags local to the command, not inherited by the subcommands. The slice flags are
+// left out as a local flag is reset on each occurrence, which would keep only the last value of a repeated flag.
+func localFlags(flags []cli.Flag) []cli.Flag {
+	for _, flag := range flags {
+		switch f := flag.(type) {
+		case *cli.StringFlag:
+			f.Local = true
+		case *cli.BoolFlag:
+			f.Local = true
+		case *cli.IntFlag:
+			f.Local = true
+		case *cli.DurationFlag:
+			f.Local = true
+		}
+	}
+	return flags
+}
+
+// listFlags are the flags of list.
+func listFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       "table",
+			Usage:       "output format: table, json or names",
+			Destination: &optListFormat,
+		},
+		&cli.BoolFlag{
+			Name:        "details",
+			Usage:       "show each model for the context length and the capabilities",
+			Destination: &optListDetails,
+		},
+	}
+}
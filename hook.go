@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+)
+
+// runOnChange runs the command of --on-change by the shell after the output file is written with changes.
+// The changes are passed in the environment, the failure of the command is warned unless --on-change-strict.
+func runOnChange(outFile string, summary syncSummary) error {
+	if optOnChange == "" {
+		return nil
+	}
+	if abs, err := filepath.Abs(outFile); err == nil {
+		outFile = abs
+	}
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.Command(shell, flag, optOnChange)
+	cmd.Env = append(os.Environ(),
+		"AICHATCONF_CONFIG="+outFile,
+		"AICHATCONF_ADDED="+strings.Join(summary.addedNames, ","),
+		"AICHATCONF_REMOVED="+strings.Join(summary.removedNames, ","),
+	)
+	// the output of the command is not mixed with the config written to stdout
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	verboseInfo("run on-change command: %s", optOnChange)
+	if err := cmd.Run(); err != nil {
+		if optOnChangeStrict {
+			return tracerr.Errorf("on-change command failed: %w", err)
+		}
+		logrus.Warnf("on-change command failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/samber/lo"
+	"github.com/zrs01/aichatconf/internal/aichat"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultOllamaAPIBase = "http://localhost:11434"
+
+// initComments are the head comments of the settings written by init.
+var initComments = map[string]string{
+	"model":       "default model, <client>:<model>",
+	"stream":      "stream the response",
+	"save":        "save the chat messages to messages.md",
+	"keybindings": "keybindings of the REPL, emacs or vi",
+	"clients":     "clients of the LLM providers, the models of the ollama client are synced by aichatconf",
+}
+
+// initConfig writes a new aichat config with an ollama client, the models of the server are synced into it
+// as by sync, and the default model is set by --model, --auto-default or the first chat model.
+func initConfig() error {
+	filename := optCfgFile
+	if filename == "" {
+		filename = optOutFile
+	}
+	if filename == "" || filename == "-" {
+		return tracerr.New("config file is required, use --config or --output")
+	}
+	if _, err := os.Stat(filename); err == nil && !optForce {
+		return tracerr.Errorf("config file already exists, use --force to overwrite: %s", filename)
+	}
+
+	if optClientName == "" {
+		optClientName = "ollama"
+	}
+	apiBase := optAPIBase
+	if apiBase == "" {
+		apiBase = defaultOllamaAPIBase
+	}
+	cfg := aichat.ConfigStruct{
+		// the model is replaced by the first chat model if not set by --model or --auto-default
+		Model:       fmt.Sprintf("%s:%s", optClientName, optDefModel),
+		Stream:      lo.ToPtr(true),
+		Save:        lo.ToPtr(true),
+		Keybindings: "emacs",
+		Clients: []aichat.Client{
+			{Type: "ollama", Name: optClientName, APIBase: apiBase},
+		},
+	}
+
+	var cfgNode yaml.Node
+	if err := cfgNode.Encode(cfg); err != nil {
+		return tracerr.Wrap(err)
+	}
+	for i := 0; i+1 < len(cfgNode.Content); i += 2 {
+		cfgNode.Content[i].HeadComment = initComments[cfgNode.Content[i].Value]
+	}
+	// the empty models list is omitted by the encoder
+	clients, _ := getNodeValue(&cfgNode, "clients", yaml.SequenceNode)
+	setNodeField(clients.Content[0], "models", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"})
+
+	body, err := yaml.Marshal(&cfgNode)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	body = append([]byte("# aichat configuration, see https://github.com/sigoden/aichat/blob/main/config.example.yaml\n\n"), body...)
+
+	// sync the models into the scaffold, the config file is only written if the sync succeeds
+	scaffold, err := os.CreateTemp("", "aichatconf-init-*.yaml")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer os.Remove(scaffold.Name())
+	if _, err := scaffold.Write(body); err != nil {
+		scaffold.Close()
+		return tracerr.Wrap(err)
+	}
+	if err := scaffold.Close(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if dir := filepath.Dir(filename); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	optCfgFile, optOutFile, optYes = scaffold.Name(), filename, true
+	return process()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ztrue/tracerr"
+)
+
+// modelChoice is a model in the interactive checklist.
+type modelChoice struct {
+	name     string
+	remove   bool // removal of an obsolete model, addition of a new one otherwise
+	selected bool
+}
+
+// isTerminal reports whether both stdin and stderr are terminals, the checklist is prompted on stderr
+// as stdout may be the output.
+func isTerminal() bool {
+	return isCharDevice(os.Stdin) && isCharDevice(os.Stderr)
+}
+
+func isCharDevice(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks the question on the terminal and reports whether it is answered yes. The answer is read from
+// the terminal rather than stdin, which may be the piped config.
+func confirm(question string) (bool, error) {
+	ttyName := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		ttyName = "CONIN$"
+	}
+	tty, err := os.Open(ttyName)
+	if err != nil {
+		return false, tracerr.Errorf("no terminal to confirm, use --yes to skip: %v", err)
+	}
+	defer tty.Close()
+	fmt.Fprint(os.Stderr, question)
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, tracerr.Wrap(err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// selectModels prompts the checklist of the models to add and remove, all selected at first, and returns
+// the unselected ones to be skipped.
+func selectModels(adds, removes []string) (skipAdds, skipRemoves []string, err error) {
+	choices := []*modelChoice{}
+	for _, name := range adds {
+		choices = append(choices, &modelChoice{name: name, selected: true})
+	}
+	for _, name := range removes {
+		choices = append(choices, &modelChoice{name: name, remove: true, selected: true})
+	}
+	if len(choices) == 0 {
+		return nil, nil, nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		printChoices(choices)
+		fmt.Fprint(os.Stderr, "Toggle by numbers separated by spaces, or press enter to confirm: ")
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			break
+		}
+		for _, field := range fields {
+			n, convErr := strconv.Atoi(field)
+			if convErr != nil || n < 1 || n > len(choices) {
+				fmt.Fprintf(os.Stderr, "invalid number: %s\n", field)
+				continue
+			}
+			choices[n-1].selected = !choices[n-1].selected
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	for _, choice := range choices {
+		if choice.selected {
+			continue
+		}
+		if choice.remove {
+			skipRemoves = append(skipRemoves, choice.name)
+		} else {
+			skipAdds = append(skipAdds, choice.name)
+		}
+	}
+	return skipAdds, skipRemoves, nil
+}
+
+func printChoices(choices []*modelChoice) {
+	header := ""
+	for i, choice := range choices {
+		title := "New models to add:"
+		if choice.remove {
+			title = "Obsolete models to remove:"
+		}
+		if title != header {
+			fmt.Fprintln(os.Stderr, title)
+			header = title
+		}
+		mark := " "
+		if choice.selected {
+			mark = "x"
+		}
+		fmt.Fprintf(os.Stderr, "  [%s] %d) %s\n", mark, i+1, choice.name)
+	}
+}
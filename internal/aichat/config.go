@@ -0,0 +1,90 @@
+// Package aichat defines the configuration types of github.com/sigoden/aichat.
+package aichat
+
+// ConfigStruct is the aichat configuration file, config.yaml.
+type ConfigStruct struct {
+	Model             string         `yaml:"model,omitempty" json:"model,omitempty"`
+	Temperature       *float64       `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	TopP              *float64       `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	DryRun            *bool          `yaml:"dry_run,omitempty" json:"dry_run,omitempty"`
+	Stream            *bool          `yaml:"stream,omitempty" json:"stream,omitempty"`
+	Save              *bool          `yaml:"save,omitempty" json:"save,omitempty"`
+	Keybindings       string         `yaml:"keybindings,omitempty" json:"keybindings,omitempty"`
+	Editor            string         `yaml:"editor,omitempty" json:"editor,omitempty"`
+	Wrap              string         `yaml:"wrap,omitempty" json:"wrap,omitempty"`
+	WrapCode          *bool          `yaml:"wrap_code,omitempty" json:"wrap_code,omitempty"`
+	FunctionCalling   *bool          `yaml:"function_calling,omitempty" json:"function_calling,omitempty"`
+	MappingTools      map[string]any `yaml:"mapping_tools,omitempty" json:"mapping_tools,omitempty"`
+	UseTools          string         `yaml:"use_tools,omitempty" json:"use_tools,omitempty"`
+	Prelude           string         `yaml:"prelude,omitempty" json:"prelude,omitempty"`
+	ReplPrelude       string         `yaml:"repl_prelude,omitempty" json:"repl_prelude,omitempty"`
+	CmdPrelude        string         `yaml:"cmd_prelude,omitempty" json:"cmd_prelude,omitempty"`
+	AgentPrelude      string         `yaml:"agent_prelude,omitempty" json:"agent_prelude,omitempty"`
+	SaveSession       *bool          `yaml:"save_session,omitempty" json:"save_session,omitempty"`
+	CompressThreshold *int           `yaml:"compress_threshold,omitempty" json:"compress_threshold,omitempty"`
+	SummarizePrompt   string         `yaml:"summarize_prompt,omitempty" json:"summarize_prompt,omitempty"`
+	SummaryPrompt     string         `yaml:"summary_prompt,omitempty" json:"summary_prompt,omitempty"`
+	RagEmbeddingModel string         `yaml:"rag_embedding_model,omitempty" json:"rag_embedding_model,omitempty"`
+	RagRerankerModel  string         `yaml:"rag_reranker_model,omitempty" json:"rag_reranker_model,omitempty"`
+	RagTopK           *int           `yaml:"rag_top_k,omitempty" json:"rag_top_k,omitempty"`
+	RagChunkSize      *int           `yaml:"rag_chunk_size,omitempty" json:"rag_chunk_size,omitempty"`
+	RagChunkOverlap   *int           `yaml:"rag_chunk_overlap,omitempty" json:"rag_chunk_overlap,omitempty"`
+	RagTemplate       string         `yaml:"rag_template,omitempty" json:"rag_template,omitempty"`
+	DocumentLoaders   map[string]any `yaml:"document_loaders,omitempty" json:"document_loaders,omitempty"`
+	Highlight         *bool          `yaml:"highlight,omitempty" json:"highlight,omitempty"`
+	LightTheme        *bool          `yaml:"light_theme,omitempty" json:"light_theme,omitempty"`
+	LeftPrompt        string         `yaml:"left_prompt,omitempty" json:"left_prompt,omitempty"`
+	RightPrompt       string         `yaml:"right_prompt,omitempty" json:"right_prompt,omitempty"`
+	ServeAddr         string         `yaml:"serve_addr,omitempty" json:"serve_addr,omitempty"`
+	UserAgent         string         `yaml:"user_agent,omitempty" json:"user_agent,omitempty"`
+	SaveShellHistory  *bool          `yaml:"save_shell_history,omitempty" json:"save_shell_history,omitempty"`
+	SyncModelsUrl     string         `yaml:"sync_models_url,omitempty" json:"sync_models_url,omitempty"`
+	Clients           []Client       `yaml:"clients,omitempty" json:"clients,omitempty"`
+}
+
+// Client is a client in the clients list.
+type Client struct {
+	Type    string         `yaml:"type" json:"type,omitempty"`
+	Name    string         `yaml:"name,omitempty" json:"name,omitempty"`
+	APIBase string         `yaml:"api_base,omitempty" json:"api_base,omitempty"`
+	APIKey  string         `yaml:"api_key,omitempty" json:"api_key,omitempty"`
+	Models  []ClientModel  `yaml:"models,omitempty" json:"models,omitempty"`
+	Patch   any            `yaml:"patch,omitempty" json:"patch,omitempty"`
+	Extra   map[string]any `yaml:"extra,omitempty" json:"extra,omitempty"`
+	// settings of the other types of clients
+	OrganizationID  string `yaml:"organization_id,omitempty" json:"organization_id,omitempty"`     // openai
+	ProjectID       string `yaml:"project_id,omitempty" json:"project_id,omitempty"`               // vertexai
+	Location        string `yaml:"location,omitempty" json:"location,omitempty"`                   // vertexai
+	AdcFile         string `yaml:"adc_file,omitempty" json:"adc_file,omitempty"`                   // vertexai
+	AccessKeyID     string `yaml:"access_key_id,omitempty" json:"access_key_id,omitempty"`         // bedrock
+	SecretAccessKey string `yaml:"secret_access_key,omitempty" json:"secret_access_key,omitempty"` // bedrock
+	SessionToken    string `yaml:"session_token,omitempty" json:"session_token,omitempty"`         // bedrock
+	Region          string `yaml:"region,omitempty" json:"region,omitempty"`                       // bedrock
+	AccountID       string `yaml:"account_id,omitempty" json:"account_id,omitempty"`               // cloudflare
+	SecretKey       string `yaml:"secret_key,omitempty" json:"secret_key,omitempty"`               // ernie
+}
+
+// ClientModel is a model of the client.
+type ClientModel struct {
+	Name                    string   `yaml:"name" json:"name,omitempty"`
+	RealName                string   `yaml:"real_name,omitempty" json:"real_name,omitempty"`
+	Type                    string   `yaml:"type,omitempty" json:"type,omitempty"`
+	MaxInputTokens          *int     `yaml:"max_input_tokens,omitempty" json:"max_input_tokens,omitempty"`
+	MaxOutputTokens         *int     `yaml:"max_output_tokens,omitempty" json:"max_output_tokens,omitempty"`
+	RequireMaxTokens        *bool    `yaml:"require_max_tokens,omitempty" json:"require_max_tokens,omitempty"`
+	InputPrice              *float64 `yaml:"input_price,omitempty" json:"input_price,omitempty"`
+	OutputPrice             *float64 `yaml:"output_price,omitempty" json:"output_price,omitempty"`
+	Temperature             *float64 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	TopP                    *float64 `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	SupportsVision          *bool    `yaml:"supports_vision,omitempty" json:"supports_vision,omitempty"`
+	SupportsFunctionCalling *bool    `yaml:"supports_function_calling,omitempty" json:"supports_function_calling,omitempty"`
+	SupportsReasoning       *bool    `yaml:"supports_reasoning,omitempty" json:"supports_reasoning,omitempty"`
+	NoStream                *bool    `yaml:"no_stream,omitempty" json:"no_stream,omitempty"`
+	NoSystemMessage         *bool    `yaml:"no_system_message,omitempty" json:"no_system_message,omitempty"`
+	SystemPromptPrefix      string   `yaml:"system_prompt_prefix,omitempty" json:"system_prompt_prefix,omitempty"`
+	MaxTokensPerChunk       *int     `yaml:"max_tokens_per_chunk,omitempty" json:"max_tokens_per_chunk,omitempty"`
+	DefaultChunkSize        *int     `yaml:"default_chunk_size,omitempty" json:"default_chunk_size,omitempty"`
+	MaxBatchSize            *int     `yaml:"max_batch_size,omitempty" json:"max_batch_size,omitempty"`
+	Patch                   any      `yaml:"patch,omitempty" json:"patch,omitempty"`
+	Keep                    *bool    `yaml:"keep,omitempty" json:"keep,omitempty"` // aichatconf only, kept regardless of ollama
+}
@@ -1,20 +1,11 @@
 package util
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/yassinebenaid/godump"
 )
 
-type MonoColor struct {
-	R, G, B int
-}
-
-func (m MonoColor) Apply(s string) string {
-	return fmt.Sprintf("%s", s)
-}
-
 func Dump(v any) error {
 	var d godump.Dumper
 
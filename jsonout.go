@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonNode encodes the node to JSON with the keys in the order of the config, the scalars are of the types
+// resolved by YAML, e.g. numbers and booleans.
+type jsonNode struct {
+	node *yaml.Node
+}
+
+func (n jsonNode) MarshalJSON() ([]byte, error) {
+	node := n.node
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return []byte("null"), nil
+		}
+		return jsonNode{node.Content[0]}.MarshalJSON()
+	case yaml.AliasNode:
+		return jsonNode{node.Alias}.MarshalJSON()
+	case yaml.MappingNode:
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			value, err := jsonNode{node.Content[i+1]}.MarshalJSON()
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	case yaml.SequenceNode:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			value, err := jsonNode{child}.MarshalJSON()
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			buf.Write(value)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	default:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		return json.Marshal(value)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// listedModel is a model of the server printed by list, the unknown fields are empty.
+type listedModel struct {
+	Name          string   `json:"name"`
+	ParameterSize string   `json:"parameter_size,omitempty"`
+	Quantization  string   `json:"quantization,omitempty"`
+	ContextLength int      `json:"context_length,omitempty"`
+	Capabilities  []string `json:"capabilities,omitempty"`
+	InConfig      bool     `json:"in_config"`
+}
+
+// listServerModels prints the models of the server of the client in the format of --format, with
+// the context length and the capabilities shown by the server with --details.
+func listServerModels() error {
+	if optListFormat != "table" && optListFormat != "json" && optListFormat != "names" {
+		return tracerr.Errorf("unknown list format: %s", optListFormat)
+	}
+	cfg, err := readAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	cfgModels, ok := getNodeValue(cfg.client, "models", yaml.SequenceNode)
+	if !ok {
+		cfgModels = &yaml.Node{Kind: yaml.SequenceNode}
+	}
+	if modelSrc, err = createClientSource(cfg.client); err != nil {
+		return tracerr.Wrap(err)
+	}
+	models, err := modelSrc.listModels()
+	if err != nil {
+		return withExitCode(exitConnError, err)
+	}
+	verboseInfo("%s models found: %d", modelSrc.name(), len(models))
+	if optListDetails {
+		modelParams = map[string]*modelParameters{}
+		if !optNoCache {
+			if modelCache, err = loadShowCache(optCacheDir); err != nil {
+				logrus.Warnf("show cache disabled: %v", err)
+			}
+		}
+	}
+
+	listed := []listedModel{}
+	for _, model := range models {
+		entry := listedModel{
+			Name:          model.name,
+			InConfig:      findModelNode(cfgModels, model.name) != nil,
+			ParameterSize: model.details.parameterSize,
+			Quantization:  model.details.quantization,
+		}
+		if optListDetails {
+			params, err := getModelParameters(model.name)
+			if err != nil {
+				logrus.Warnf("model details not available: %v", err)
+			} else {
+				entry.ContextLength = max(params.maxContextLength, 0)
+				for _, capability := range params.capabilities {
+					entry.Capabilities = append(entry.Capabilities, string(capability))
+				}
+			}
+		}
+		listed = append(listed, entry)
+	}
+	if modelCache != nil {
+		modelCache.save()
+	}
+
+	switch optListFormat {
+	case "json":
+		body, err := json.MarshalIndent(listed, "", "  ")
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		fmt.Printf("%s\n", body)
+	case "names":
+		for _, entry := range listed {
+			fmt.Println(entry.Name)
+		}
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "NAME\tSIZE\tQUANTIZATION\tIN CONFIG"
+		if optListDetails {
+			header = "NAME\tSIZE\tQUANTIZATION\tCONTEXT\tCAPABILITIES\tIN CONFIG"
+		}
+		fmt.Fprintln(w, header)
+		for _, entry := range listed {
+			inConfig := "no"
+			if entry.InConfig {
+				inConfig = "yes"
+			}
+			columns := []string{entry.Name, orDash(entry.ParameterSize), orDash(entry.Quantization)}
+			if optListDetails {
+				contextLength := ""
+				if entry.ContextLength > 0 {
+					contextLength = strconv.Itoa(entry.ContextLength)
+				}
+				columns = append(columns, orDash(contextLength), orDash(strings.Join(entry.Capabilities, ",")))
+			}
+			fmt.Fprintln(w, strings.Join(append(columns, inConfig), "\t"))
+		}
+		if err := w.Flush(); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// orDash returns the value, or "-" if empty to keep the table aligned.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+)
+
+// llamaServerSource is the model source of llama.cpp llama-server instances, one model each,
+// the models of all servers are merged into the client.
+type llamaServerSource struct {
+	servers []llamaServer
+	models  map[string]*modelParameters // parameters found in the responses
+}
+
+// llamaServer is a llama-server instance.
+type llamaServer struct {
+	httpClient *http.Client
+	baseURL    *url.URL // root of the server, the /v1 of api_base is removed
+}
+
+func (s *llamaServerSource) name() string {
+	return "llama-server"
+}
+
+// listModels returns the models of GET /v1/models of every server, and their context length from GET /props.
+// Servers failed to answer are skipped with a warning, as long as one of them answers.
+func (s *llamaServerSource) listModels() ([]ollamaModel, error) {
+	models := []ollamaModel{}
+	s.models = map[string]*modelParameters{}
+	var lastErr error
+	for _, server := range s.servers {
+		ids, err := server.getModels()
+		if err != nil {
+			logrus.Warnf("llama-server not available, skipped: %s: %v", server.baseURL, err)
+			lastErr = err
+			continue
+		}
+		ctxLen, err := server.getContextLength()
+		if err != nil {
+			logrus.Warnf("llama-server props not available, context length unknown: %s: %v", server.baseURL, err)
+		}
+		for _, id := range ids {
+			if lo.ContainsBy(models, func(m ollamaModel) bool { return m.name == id }) {
+				continue
+			}
+			params := newModelParameters()
+			if ctxLen > 0 {
+				params.maxContextLength = ctxLen
+			}
+			s.models[id] = params
+			models = append(models, ollamaModel{name: id, listedName: id})
+		}
+	}
+	if len(models) == 0 && lastErr != nil {
+		return []ollamaModel{}, tracerr.Wrap(lastErr)
+	}
+	return models, nil
+}
+
+// showModel returns the parameters found in the responses.
+func (s *llamaServerSource) showModel(model string) (*modelParameters, error) {
+	if params, ok := s.models[model]; ok {
+		return params, nil
+	}
+	return newModelParameters(), tracerr.Errorf("%w: %s", errModelNotFound, model)
+}
+
+// getModels returns the model ids of the server.
+func (server llamaServer) getModels() ([]string, error) {
+	var body struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := server.get("/v1/models", &body); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return lo.FilterMap(body.Data, func(data struct {
+		ID string `json:"id"`
+	}, _ int) (string, bool) {
+		return data.ID, data.ID != ""
+	}), nil
+}
+
+// getContextLength returns the context length of the server.
+func (server llamaServer) getContextLength() (int, error) {
+	var body struct {
+		DefaultGenerationSettings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+	}
+	if err := server.get("/props", &body); err != nil {
+		return -1, tracerr.Wrap(err)
+	}
+	return body.DefaultGenerationSettings.NCtx, nil
+}
+
+func (server llamaServer) get(path string, v any) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.baseURL.JoinPath(path).String(), nil)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	resp, err := server.httpClient.Do(req)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return tracerr.Errorf("%s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return tracerr.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// createLlamaServerSource creates the source of the api_base and the extra hosts.
+func createLlamaServerSource(apiBases []string, apiKey string, tlsOpts tlsOptions, proxyURL *url.URL) (*llamaServerSource, error) {
+	src := &llamaServerSource{}
+	for _, apiBase := range apiBases {
+		httpClient, u, err := createHTTPClient(apiBase, apiKey, tlsOpts, proxyURL)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if u == nil {
+			continue
+		}
+		u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/v1")
+		src.servers = append(src.servers, llamaServer{httpClient: httpClient, baseURL: u})
+	}
+	if len(src.servers) == 0 {
+		return nil, tracerr.New("api_base is required for llama-server client")
+	}
+	return src, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	olmmodel "github.com/ollama/ollama/types/model"
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+)
+
+// lmstudioSource is the model source of LM Studio by its REST API, which tells the
+// context length and the type of the models beyond the OpenAI compatible endpoint.
+type lmstudioSource struct {
+	httpClient *http.Client
+	baseURL    *url.URL                    // api_base, e.g. http://localhost:1234/v1, only the host is used
+	models     map[string]*modelParameters // parameters found in the models response
+}
+
+func (s *lmstudioSource) name() string {
+	return "lmstudio"
+}
+
+// listModels returns the downloaded models of GET /api/v0/models, including the not loaded
+// ones since aichat can trigger the loading.
+func (s *lmstudioSource) listModels() ([]ollamaModel, error) {
+	u := url.URL{Scheme: s.baseURL.Scheme, Host: s.baseURL.Host, Path: "/api/v0/models"}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return []ollamaModel{}, tracerr.Wrap(err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return []ollamaModel{}, tracerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []ollamaModel{}, tracerr.Errorf("list models: %s", resp.Status)
+	}
+	var body struct {
+		Data []struct {
+			ID               string `json:"id"`
+			Type             string `json:"type"` // llm, vlm or embeddings
+			State            string `json:"state"`
+			MaxContextLength int    `json:"max_context_length"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return []ollamaModel{}, tracerr.Errorf("list models: %w", err)
+	}
+	models := []ollamaModel{}
+	s.models = map[string]*modelParameters{}
+	for _, data := range body.Data {
+		if data.ID == "" || lo.ContainsBy(models, func(m ollamaModel) bool { return m.name == data.ID }) {
+			continue
+		}
+		params := newModelParameters()
+		if data.MaxContextLength > 0 {
+			params.maxContextLength = data.MaxContextLength
+		}
+		switch data.Type {
+		case "embeddings":
+			params.capabilities = []olmmodel.Capability{olmmodel.CapabilityEmbedding}
+		case "vlm":
+			params.capabilities = []olmmodel.Capability{olmmodel.CapabilityCompletion, olmmodel.CapabilityVision}
+		case "llm":
+			params.capabilities = []olmmodel.Capability{olmmodel.CapabilityCompletion}
+		}
+		verboseDebug("lmstudio model: %s, type: %s, state: %s", data.ID, data.Type, data.State)
+		s.models[data.ID] = params
+		models = append(models, ollamaModel{name: data.ID, listedName: data.ID})
+	}
+	return models, nil
+}
+
+// showModel returns the parameters found in the models response.
+func (s *lmstudioSource) showModel(model string) (*modelParameters, error) {
+	if params, ok := s.models[model]; ok {
+		return params, nil
+	}
+	return newModelParameters(), tracerr.Errorf("%w: %s", errModelNotFound, model)
+}
@@ -2,246 +2,611 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
+	"io"
 	"os"
-	"regexp"
-	"sort"
+	"path"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	nested "github.com/antonfisher/nested-logrus-formatter"
-	"github.com/ollama/ollama/api"
-	olmapi "github.com/ollama/ollama/api"
 	olmmodel "github.com/ollama/ollama/types/model"
 	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
+	"github.com/zrs01/aichatconf/internal/aichat"
 	"github.com/ztrue/tracerr"
 	"gopkg.in/yaml.v3"
 )
 
 var (
-	version       string
-	optDebug      bool
-	optQuiet      bool
-	optCfgFile    string
-	optClientName string
-	optOutFile    string
-	optExclude    string // models exclude
-	optDefModel   string // default model
-	ollamaClient  *olmapi.Client
+	version           string
+	optDebug          bool
+	optLogFormat      string
+	optLogLevel       string
+	optColor          string // auto, always or never
+	optQuiet          bool
+	optVerbose        int // count of -v
+	optCfgFile        string
+	optClientName     string
+	optOutFile        string
+	optFormat         string                      // output format
+	optForce          bool                        // overwrite the existing config file by init
+	optNoValidate     bool                        // write without validating the result
+	optNoVerify       bool                        // write without verifying the output
+	optUseSyncURL     bool                        // fill the parameters unknown by the server from the models registry
+	optFixDeprecated  bool                        // rename the deprecated keys of the config
+	optSource         string                      // model source, default by the client
+	optModelsFile     string                      // models dump file of the offline mode
+	optExclude        string                      // models exclude
+	optInclude        string                      // models include, the others are excluded
+	optExclMode       string                      // models exclude matching mode
+	optKeep           string                      // models always kept
+	optNormLatest     string                      // normalize the :latest tag of models
+	optDefModel       string                      // default model
+	optMinCtx         int                         // minimum context length
+	optMinCtxStr      bool                        // exclude models with unknown context length under min context
+	optMaxCtx         int                         // maximum context length, the detected one is capped
+	optKeepOnErr      bool                        // keep config unchanged when ollama is unreachable
+	optEmitType       bool                        // emit type: chat for non-embedding models
+	optReranker       string                      // models taken as rerankers
+	optSkipDefParams  bool                        // skip temperature and top_p equal to the ollama defaults
+	optNoRemove       bool                        // keep the obsolete models
+	optNoAdd          bool                        // do not add new models
+	optInteractive    bool                        // select the models to add and remove
+	optNoFixDefault   bool                        // keep the default model even if it is removed
+	optConfirm        bool                        // confirm before writing the output file
+	optModelExact     bool                        // the default model by the full name
+	optAutoDefault    string                      // strategy choosing the default model
+	optPruneClients   bool                        // remove the client left without models
+	optCtxKeyPrefix   string                      // preferred prefix of the context length key in the model info
+	optStripLatest    bool                        // same as --normalize-latest strip
+	optWatch          bool                        // sync again on the interval until interrupted
+	optInterval       time.Duration               // interval of --watch
+	optDedupe         bool                        // keep one model of the same digest
+	optPreferTag      string                      // tag preferred by dedupe
+	optDiffOnly       bool                        // print the changes of the models as JSON instead of the config
+	optCheck          bool                        // report whether the config is out of date, nothing is written
+	optListFormat     string                      // format of list: table, json or names
+	optListDetails    bool                        // show the models for list
+	optPatchNumCtx    string                      // num_ctx patched into the chat models, a number or max
+	optPatch          string                      // patch of the new models, JSON or YAML
+	optAliases        []string                    // alias=model entries
+	optAliasFile      string                      // file of the aliases to the models
+	optSet            []string                    // key=value entries of the top level of the config
+	optGlobalTemp     string                      // temperature of the top level of the config
+	optStripTag       bool                        // sync the models under the names without the tags
+	optClientType     string                      // type of the client added by add-client
+	optStyle          string                      // style of the collections of the output: block, flow or preserve
+	optDryRun         bool                        // print the output instead of writing the output file
+	optIndent         int                         // indentation of the yaml output
+	optStamp          bool                        // stamp the models of the client with the version and the time of the sync
+	optAnnotate       bool                        // comment the models with the sizes and the quantization
+	optAnnotateSet    bool                        // --annotate is given, --annotate=false removes the comments
+	optAllOllama      bool                        // sync all the clients of type ollama
+	optPruneMode      string                      // delete or comment out the removed models
+	optRunningOnly    bool                        // sync the models loaded in memory of ollama only
+	optSort           string                      // order of the models: name or size
+	optOnChange       string                      // command run when the output file is written with changes
+	optOnChangeStrict bool                        // fail the sync when the command of --on-change fails
+	optAddSync        bool                        // sync the models of the client added by add-client
+	optCapFields      []string                    // capability=field mappings
+	optCapFieldsFile  string                      // file of the capability to field mapping
+	optAllParams      bool                        // write all parameters of the models
+	optYes            bool                        // write the output file without confirmation
+	optRagEmbModel    string                      // rag_embedding_model to set
+	optAutoRagEmb     bool                        // point rag_embedding_model at the first embedding model when missing or stale
+	optRagRerankModel string                      // rag_reranker_model to set
+	optAutoRagRerank  bool                        // point rag_reranker_model at the first reranker model when missing or stale
+	optEmbChunk       int                         // default chunk size of embedding models
+	optEmbMaxTokens   int                         // max tokens per chunk of embedding models
+	optEmbBatch       int                         // max batch size of embedding models
+	optDefaults       string                      // defaults file of new models
+	optDefExist       bool                        // apply the defaults to existing models
+	optOverrides      string                      // overrides file of models
+	optAPIBase        string                      // api_base overriding the client setting
+	optAPIKey         string                      // api_key overriding the client setting
+	optEnvPrefix      string                      // prefix of environment variables overriding api_base and api_key
+	optAuthHeader     string                      // auth header name
+	optAuthScheme     string                      // auth scheme, empty to send the raw api key
+	optUnixSocket     string                      // unix domain socket of ollama
+	optCACert         string                      // CA certificate file appended to the root pool
+	optInsecure       bool                        // skip TLS certificate verification
+	optClientCert     string                      // client certificate file for mTLS
+	optClientKey      string                      // client key file for mTLS
+	optProxy          string                      // proxy of ollama
+	optCacheDir       string                      // directory of the show cache
+	optNoCache        bool                        // disable the show cache
+	optRefreshCache   bool                        // re-fetch the show responses of all models
+	modelSrc          modelSource                 // model source of the client
+	modelParams       map[string]*modelParameters // model parameters fetched in this run
+	modelCache        *showCache                  // show cache of ollama models, nil if disabled
 )
 
+// default parameters of ollama, skipped by --skip-default-params
+const (
+	ollamaDefaultTemperature = 0.8
+	ollamaDefaultTopP        = 0.9
+)
+
+// syncSummary counts the changes made to the models of the client.
+type syncSummary struct {
+	added         int
+	removed       int
+	excluded      int
+	belowMinCtx   int
+	overridden    int
+	skippedAdd    int // new models not added by --no-add
+	skippedRemove int // obsolete models not removed by --no-remove
+	set           int // top level keys changed by --set
+	clients       int // clients added or removed by add-client or remove-client
+	addedNames    []string
+	removedNames  []string // models removed, also the ones below the min context
+}
+
+func (s syncSummary) String() string {
+	return fmt.Sprintf("summary: %d added, %d removed, %d excluded, %d below min context, %d overridden%s",
+		s.added, s.removed, s.excluded, s.belowMinCtx, s.overridden, s.skipped())
+}
+
+// changes returns the number of models added, removed and overridden, the keys set and the clients changed.
+func (s syncSummary) changes() int {
+	return s.modelChanges() + s.set + s.clients
+}
+
+// plus returns the sum of the summaries.
+func (s syncSummary) plus(o syncSummary) syncSummary {
+	return syncSummary{
+		added:         s.added + o.added,
+		removed:       s.removed + o.removed,
+		excluded:      s.excluded + o.excluded,
+		belowMinCtx:   s.belowMinCtx + o.belowMinCtx,
+		overridden:    s.overridden + o.overridden,
+		skippedAdd:    s.skippedAdd + o.skippedAdd,
+		skippedRemove: s.skippedRemove + o.skippedRemove,
+		set:           s.set + o.set,
+		clients:       s.clients + o.clients,
+		addedNames:    append(append([]string{}, s.addedNames...), o.addedNames...),
+		removedNames:  append(append([]string{}, s.removedNames...), o.removedNames...),
+	}
+}
+
+// modelChanges returns the number of models added, removed and overridden.
+func (s syncSummary) modelChanges() int {
+	return s.added + s.removed + s.belowMinCtx + s.overridden
+}
+
+// skipped returns the skipped additions and removals, the keys set and the clients changed, for the summary line.
+func (s syncSummary) skipped() string {
+	var parts []string
+	if optNoAdd {
+		parts = append(parts, fmt.Sprintf(", skipped addition of %d", s.skippedAdd))
+	}
+	if optNoRemove {
+		parts = append(parts, fmt.Sprintf(", skipped removal of %d", s.skippedRemove))
+	}
+	if len(optSet) > 0 || optGlobalTemp != "" {
+		parts = append(parts, fmt.Sprintf(", %d keys set", s.set))
+	}
+	if s.clients > 0 {
+		parts = append(parts, fmt.Sprintf(", %d clients changed", s.clients))
+	}
+	return strings.Join(parts, "")
+}
+
 func main() {
 	initLogrus()
+	// -v is of --verbose, the version is by --version only
+	cli.VersionFlag = &cli.BoolFlag{Name: "version", Usage: "print the version", HideDefault: true, Local: true}
 
 	cmd := &cli.Command{
 		Name:    "aichatconf",
 		Usage:   "A simple configuration tool for github.com/sigoden/aichat",
 		Version: version,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:        "config",
-				Aliases:     []string{"c"},
-				Required:    true,
-				Usage:       "config file of aichat",
-				Destination: &optCfgFile,
+		// the sync flags are accepted without the subcommand for backward compatibility
+		Flags:  append(globalFlags(), localFlags(syncFlags())...),
+		Before: setupLogging,
+		Action: runSync,
+		Commands: []*cli.Command{
+			{
+				Name:   "sync",
+				Usage:  "sync the models of the client with the server, the default command",
+				Flags:  syncFlags(),
+				Before: setupLogging,
+				Action: runSync,
 			},
-			&cli.StringFlag{
-				Name:        "client",
-				Aliases:     []string{"n"},
-				Usage:       "client name",
-				Destination: &optClientName,
+			{
+				Name:   "list",
+				Usage:  "list the models of the server and whether they are in the config",
+				Flags:  listFlags(),
+				Before: setupLogging,
+				Action: func(context.Context, *cli.Command) error {
+					return listServerModels()
+				},
 			},
-			&cli.StringFlag{
-				Name:        "model",
-				Aliases:     []string{"m"},
-				Usage:       "default model",
-				Destination: &optDefModel,
+			{
+				Name:   "check",
+				Usage:  "check the config and the server of the client for the problems of aichat",
+				Before: setupLogging,
+				Action: func(context.Context, *cli.Command) error {
+					return doctor()
+				},
 			},
-			&cli.StringFlag{
-				Name:        "exclude",
-				Aliases:     []string{"e"},
-				Usage:       "models exclude, split by comma",
-				Destination: &optExclude,
+			{
+				Name:   "validate",
+				Usage:  "check the keys, the values, the clients, the models and the default model of the config, without the server",
+				Before: setupLogging,
+				Action: func(context.Context, *cli.Command) error {
+					return validateFile()
+				},
 			},
-			&cli.StringFlag{
-				Name:        "output",
-				Aliases:     []string{"o"},
-				Usage:       "output file, default is stdout",
-				Destination: &optOutFile,
+			{
+				Name:   "dump-models",
+				Usage:  "dump the models of ollama to a file for --models-file on another machine",
+				Before: setupLogging,
+				Action: func(context.Context, *cli.Command) error {
+					return dumpModels()
+				},
 			},
-			&cli.BoolFlag{
-				Name:        "quiet",
-				Aliases:     []string{"q"},
-				Value:       false,
-				Usage:       "suppress all information output",
-				Destination: &optQuiet,
+			{
+				Name:   "remove-client",
+				Usage:  "remove the client of --client from the config",
+				Before: setupLogging,
+				Flags: append(writeFlags(), &cli.BoolFlag{
+					Name:        "force",
+					Usage:       "remove the client referred by the default model or the rag models",
+					Destination: &optForce,
+				}),
+				Action: func(context.Context, *cli.Command) error {
+					return removeClient()
+				},
 			},
-			&cli.BoolFlag{
-				Name:        "debug",
-				Aliases:     []string{"d"},
-				Required:    false,
-				Usage:       "enable debug mode",
-				Destination: &optDebug,
+			{
+				Name:   "add-client",
+				Usage:  "add a client of --type and --name to the config, and sync its models with --sync",
+				Before: setupLogging,
+				Flags: append(syncFlags(),
+					&cli.StringFlag{
+						Name:        "type",
+						Usage:       "type of the client, e.g. openai-compatible or gemini",
+						Required:    true,
+						Destination: &optClientType,
+					},
+					&cli.StringFlag{
+						Name:        "name",
+						Usage:       "name of the client, default the type",
+						Destination: &optClientName,
+					},
+					&cli.BoolFlag{
+						Name:        "sync",
+						Usage:       "sync the models of the client, for ollama, openai-compatible and lmstudio",
+						Destination: &optAddSync,
+					},
+				),
+				Action: func(context.Context, *cli.Command) error {
+					return addClient()
+				},
+			},
+			{
+				Name:   "init",
+				Usage:  "write a new aichat config with an ollama client and its models to --config or --output",
+				Before: setupLogging,
+				Flags: append(syncFlags(), &cli.BoolFlag{
+					Name:        "force",
+					Usage:       "overwrite the existing config file",
+					Destination: &optForce,
+				}),
+				Action: func(context.Context, *cli.Command) error {
+					return initConfig()
+				},
 			},
-		},
-		Action: func(context.Context, *cli.Command) error {
-			if optDebug {
-				logrus.SetLevel(logrus.DebugLevel)
-			}
-			return process()
 		},
 	}
 
+	// stdout is for the output only, e.g. the config, the usage errors are logged without the help
+	// -vv is counted as two -v
+	cmd.OnUsageError, cmd.UseShortOptionHandling = usageError, true
+	for _, sub := range cmd.Commands {
+		sub.OnUsageError, sub.UseShortOptionHandling = usageError, true
+	}
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		if optDebug {
-			logrus.Error(tracerr.SprintSourceColor(err, 0))
+		entry := logrus.NewEntry(logrus.StandardLogger())
+		if optLogFormat == "json" {
+			entry = entry.WithField("exit_code", exitCode(err))
+		}
+		if optDebug && useColor() {
+			entry.Error(tracerr.SprintSourceColor(err, 0))
+		} else if optDebug {
+			entry.Error(tracerr.SprintSource(err, 0))
 		} else {
-			logrus.Error(err)
+			entry.Error(err)
 		}
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
+// usageError returns the usage error of the command to be logged, the help is not printed to stdout.
+func usageError(_ context.Context, cmd *cli.Command, err error, _ bool) error {
+	return tracerr.Errorf("%w, see %s --help", err, cmd.FullName())
+}
+
+// runSync syncs the models once, or repeatedly with --watch.
+func runSync(ctx context.Context, _ *cli.Command) error {
+	if optWatch {
+		return watch(ctx, optInterval)
+	}
+	return syncOnce()
+}
+
+// syncOnce syncs the models of the client, or of all the clients of type ollama with --all-ollama.
+func syncOnce() error {
+	if optAllOllama {
+		return syncAllOllama()
+	}
+	return process()
+}
+
 func process() error {
-	/* -------------------------------------------------------------------------- */
-	/*                          READ AICHAT CONFIGURATION                         */
-	/* -------------------------------------------------------------------------- */
-	verboseInfo("aichat configuration read: %s", optCfgFile)
-	cfgBody, err := os.ReadFile(optCfgFile)
+	if optDefModel != "" && optAutoDefault != "" {
+		return tracerr.New("--model and --auto-default cannot be used together")
+	}
+	if optCheck && !isConfigFormat() {
+		return tracerr.Errorf("--check and --format %s cannot be used together", optFormat)
+	}
+	if _, err := patchNumCtx(0); err != nil {
+		return tracerr.Wrap(err)
+	}
+	newModelPatch, err := parsePatch(optPatch)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
-	// prepend "---" to the file if missing to preserve first line comments in YAML after unmarshal
-	if len(cfgBody) >= 3 && string(cfgBody[:3]) != "---" {
-		cfgBody = []byte("---\n" + string(cfgBody))
+	if err := checkPruneMode(); err != nil {
+		return tracerr.Wrap(err)
 	}
-
-	// use yaml.Node type to unmarshal in order to keep the comment
-	var cfgDocNode yaml.Node
-	if err := yaml.Unmarshal(cfgBody, &cfgDocNode); err != nil {
+	if err := checkSortMode(); err != nil {
 		return tracerr.Wrap(err)
 	}
-	if len(cfgDocNode.Content) == 0 {
-		return tracerr.New("empty config file")
+	entries := optSet
+	if optGlobalTemp != "" {
+		entries = append(append([]string{}, optSet...), "temperature="+optGlobalTemp)
 	}
-
-	// find the default client and model
-	var cfgDefModelClient, cfgDefModelName string
-	var cfgDefModelNode *yaml.Node
-	{
-		node, ok := getNodeValue(cfgDocNode.Content[0], "model", yaml.ScalarNode)
-		if ok {
-			re := regexp.MustCompile(`^([^:]+):(.+)$`)
-			match := re.FindStringSubmatch(node.Value)
-			if len(match) > 2 {
-				cfgDefModelNode = node
-				cfgDefModelClient = strings.TrimSpace(match[1])
-				cfgDefModelName = strings.TrimSpace(match[2])
-			}
-		}
+	settings, err := parseSettings(entries)
+	if err != nil {
+		return tracerr.Wrap(err)
 	}
-
-	verboseInfo("default model found: %s:%s", cfgDefModelClient, cfgDefModelName)
-	// find the clients
-	cfgClients, _ := getNodeValue(cfgDocNode.Content[0], "clients", yaml.SequenceNode)
-	var cfgOllamaClient *yaml.Node = nil
-	verboseInfo("clients found: %d", len(cfgClients.Content))
-
-	// find the ollama client and its models
-	if optClientName == "" {
-		// use client in the model as default if user does not provided
-		optClientName = cfgDefModelClient
-	}
-	var cfgOllamaModels *yaml.Node
-	for _, cn := range cfgClients.Content {
-		for j, node := range cn.Content {
-			if node.Kind == yaml.ScalarNode && node.Value == "name" {
-				if cn.Content[j+1].Kind == yaml.ScalarNode && cn.Content[j+1].Value == optClientName {
-					cfgOllamaClient = cn
-					cfgOllamaModels, _ = getNodeValue(cn, "models", yaml.SequenceNode)
-					if cfgOllamaModels == nil {
-						verboseInfo("models found: 0")
-					} else {
-						verboseInfo("models found: %d", len(cfgOllamaModels.Content))
-					}
-				}
-			}
+	if err := loadCapabilityFields(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := loadAliases(); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if optStripLatest {
+		if optNormLatest != "" && optNormLatest != "strip" {
+			return tracerr.New("--strip-latest and --normalize-latest add cannot be used together")
 		}
+		optNormLatest = "strip"
+	}
+	/* -------------------------------------------------------------------------- */
+	/*                          READ AICHAT CONFIGURATION                         */
+	/* -------------------------------------------------------------------------- */
+	cfg, err := readAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
 	}
-	if cfgOllamaClient == nil {
-		return tracerr.Errorf("ollama client name (%s) not found", optClientName)
+	cfgOrigBody, cfgDocNode, cfgClients, cfgOllamaClient := cfg.origBody, cfg.doc, cfg.clients, cfg.client
+	cfgDefModelNode, cfgDefModelClient, cfgDefModelName := cfg.defModelNode, cfg.defModelClient, cfg.defModelName
+	fixDeprecatedKeys(cfg)
+	if optUseSyncURL {
+		syncURL := defaultSyncModelsURL
+		if node, ok := getNodeValue(cfgDocNode.Content[0], "sync_models_url", yaml.ScalarNode); ok && node.Value != "" {
+			syncURL = node.Value
+		}
+		loadModelRegistry(syncURL)
 	}
-	// create model node if not exists
+
+	// find the models of the client, create the node if not exists
+	cfgOllamaModels, _ := getNodeValue(cfgOllamaClient, "models", yaml.SequenceNode)
 	if cfgOllamaModels == nil {
+		verboseInfo("models found: 0")
 		cfgOllamaModels = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{}}
 		cfgOllamaClient.Content = append(cfgOllamaClient.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "models"})
 		cfgOllamaClient.Content = append(cfgOllamaClient.Content, cfgOllamaModels)
-		verboseInfo("models node created")
-	}
-
-	// create ollama client
-	{
-		cfgOllamaAPIKey := ""
-		if apiKeyNode, ok := getNodeValue(cfgOllamaClient, "api_key", yaml.ScalarNode); ok {
-			cfgOllamaAPIKey = apiKeyNode.Value
-			verboseInfo("api_key found")
+		verboseDebug("models node created")
+	} else {
+		verboseInfo("models found: %d", len(cfgOllamaModels.Content))
+		if len(cfgOllamaModels.Content) == 0 {
+			// an empty list is "[]" in flow style, e.g. by init, write the models in block style
+			cfgOllamaModels.Style = 0
 		}
+	}
 
-		cfgOllamaAPIBase := ""
-		if apiBaseNode, ok := getNodeValue(cfgOllamaClient, "api_base", yaml.ScalarNode); ok {
-			cfgOllamaAPIBase = apiBaseNode.Value
-			verboseInfo("api_base found: %s", cfgOllamaAPIBase)
-		} else {
-			verboseInfo("api_base not found, use default")
-		}
-		c, err := createOllamaClient(cfgOllamaAPIBase, cfgOllamaAPIKey)
-		if err != nil {
-			return tracerr.Wrap(err)
-		}
-		ollamaClient = c
+	if modelSrc, err = createClientSource(cfgOllamaClient); err != nil {
+		return tracerr.Wrap(err)
 	}
 
 	/* -------------------------------------------------------------------------- */
 	/*                                OLLAMA MODELS                               */
 	/* -------------------------------------------------------------------------- */
-	ollamaModels, err := getOllamaModels()
+	listed, err := modelSrc.listModels()
 	if err != nil {
-		return tracerr.Wrap(err)
+		if !optKeepOnErr {
+			return withExitCode(exitConnError, err)
+		}
+		logrus.Warnf("%s models not available, config unchanged: %v", modelSrc.name(), err)
+		if optOutFile != "" {
+			verboseInfo("write skipped: %s", optOutFile)
+		} else {
+			verboseInfo("write to: stdout")
+			fmt.Printf("%s\n", strings.TrimSpace(string(cfgOrigBody)))
+		}
+		return nil
 	}
-	verboseInfo("ollama models found: %d", len(ollamaModels))
-	// exclude models
-	if optExclude != "" {
-		excludeModels := strings.Split(optExclude, ",")
-		lo.ForEach(excludeModels, func(model string, _ int) {
-			model = strings.TrimSpace(model)
-		})
-		ollamaModels = lo.Filter(ollamaModels, func(model string, _ int) bool {
-			for _, excludeModel := range excludeModels {
-				if strings.Contains(model, excludeModel) {
-					verboseInfo("exclude model: %s", model)
-					return false
+	modelParams = map[string]*modelParameters{}
+	if !optNoCache {
+		if modelCache, err = loadShowCache(optCacheDir); err != nil {
+			logrus.Warnf("show cache disabled: %v", err)
+		}
+	}
+	var summary syncSummary
+	verboseInfo("%s models found: %d", modelSrc.name(), len(listed))
+	// exclude models, the patterns of the command line and of extra.sync_exclude / extra.sync_include of the
+	// client are unioned. An empty entry would exclude every model in substring mode, they are dropped by splitList
+	excludeModels := append(splitList(optExclude), getExtraList(cfgOllamaClient, "sync_exclude")...)
+	includeModels := append(splitList(optInclude), getExtraList(cfgOllamaClient, "sync_include")...)
+	if len(excludeModels) > 0 || len(includeModels) > 0 {
+		var matchErr error
+		matchAny := func(patterns []string, model string) bool {
+			for _, pattern := range patterns {
+				matched, err := matchModelName(optExclMode, pattern, model)
+				if err != nil {
+					matchErr = err
+				}
+				if matched {
+					return true
 				}
 			}
+			return false
+		}
+		listed = lo.Filter(listed, func(model ollamaModel, _ int) bool {
+			if (len(includeModels) > 0 && !matchAny(includeModels, model.name)) || matchAny(excludeModels, model.name) {
+				verboseModel(logrus.TraceLevel, "exclude", model.name, "exclude model: %s", model.name)
+				summary.excluded++
+				return false
+			}
 			return true
 		})
+		if matchErr != nil {
+			return tracerr.Wrap(matchErr)
+		}
+	}
+	// keep one model of the same blob under several tags, the others are pruned as obsolete
+	if optDedupe {
+		if listed, err = dedupeByDigest(listed); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	if optStripTag {
+		listed = stripTagAliases(listed)
 	}
+	// the models having aliases are synced under the aliases
+	ollamaModels := applyAliases(modelNames(listed))
 
+	var defaultsNode *yaml.Node
+	if optDefaults != "" {
+		node, err := loadModelDefaults(optDefaults)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		defaultsNode = node
+	}
+	var overrides []overrideRule
+	if optOverrides != "" {
+		rules, err := loadOverrides(optOverrides)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		overrides = rules
+	}
+
+	keepModels := splitList(optKeep)
+	modelsBefore := snapshotModels(cfgOllamaModels)
+	pruned := loadPrunedModels(cfgOllamaClient)
+	// let the user pick the models to add and remove
+	var skipAdds, skipRemoves []string
+	if optInteractive {
+		if optCfgFile == "-" || !isTerminal() {
+			verboseInfo("interactive mode skipped, not a terminal")
+		} else {
+			adds := lo.Filter(ollamaModels, func(model string, _ int) bool { return findModelNode(cfgOllamaModels, model) == nil })
+			removes := []string{}
+			for _, cfgModel := range cfgOllamaModels.Content {
+				cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+				if ok && !isKeptModel(cfgModel, cfgModelName.Value, keepModels) && !lo.Contains(ollamaModels, cfgModelName.Value) {
+					removes = append(removes, cfgModelName.Value)
+				}
+			}
+			if skipAdds, skipRemoves, err = selectModels(adds, removes); err != nil {
+				return tracerr.Wrap(err)
+			}
+		}
+	}
 	// remove obsolete models
 	{
 		newModels := []*yaml.Node{}
 		for _, cfgModel := range cfgOllamaModels.Content {
 			cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+			// rename the model of the other form of the :latest tag in place, e.g. llama3:latest to llama3
+			if ok && optNormLatest != "" {
+				if name, _ := normalizeLatest(cfgModelName.Value); name != cfgModelName.Value &&
+					lo.Contains(ollamaModels, name) && findModelNode(cfgOllamaModels, name) == nil {
+					verboseModel(logrus.DebugLevel, "rename", name, "rename model: %s -> %s", cfgModelName.Value, name)
+					cfgModelName.Value = name
+				}
+			}
+			// rename the model synced under an alias in place, keeping the fields set by hand
+			if ok && !lo.Contains(ollamaModels, cfgModelName.Value) {
+				if alias := aliasOf(cfgModelName.Value); alias != "" && lo.Contains(ollamaModels, alias) && findModelNode(cfgOllamaModels, alias) == nil {
+					verboseModel(logrus.DebugLevel, "rename", alias, "rename model: %s -> %s", cfgModelName.Value, alias)
+					cfgModelName.Value = alias
+				}
+			}
 			if ok {
-				if lo.Contains(ollamaModels, cfgModelName.Value) {
+				if isKeptModel(cfgModel, cfgModelName.Value, keepModels) {
+					verboseModel(logrus.TraceLevel, "keep", cfgModelName.Value, "keep model: %s", cfgModelName.Value)
+					newModels = append(newModels, cfgModel)
+				} else if lo.Contains(skipRemoves, cfgModelName.Value) {
+					verboseModel(logrus.TraceLevel, "skip", cfgModelName.Value, "skip removal of model, not selected: %s", cfgModelName.Value)
 					newModels = append(newModels, cfgModel)
+				} else if optNoRemove && (!lo.Contains(ollamaModels, cfgModelName.Value) || belowMinContext(cfgModelName.Value)) {
+					verboseModel(logrus.TraceLevel, "skip", cfgModelName.Value, "skip removal of model: %s", cfgModelName.Value)
+					summary.skippedRemove++
+					newModels = append(newModels, cfgModel)
+				} else if !lo.Contains(ollamaModels, cfgModelName.Value) {
+					verboseModel(logrus.DebugLevel, "remove", cfgModelName.Value, "remove model: %s", cfgModelName.Value)
+					summary.removed++
+					summary.removedNames = append(summary.removedNames, cfgModelName.Value)
+					if optPruneMode == "comment" {
+						if err := pruned.add(cfgModel); err != nil {
+							return tracerr.Wrap(err)
+						}
+					}
+				} else if belowMinContext(cfgModelName.Value) {
+					verboseModel(logrus.DebugLevel, "remove", cfgModelName.Value, "remove model, context length below %d: %s", optMinCtx, cfgModelName.Value)
+					summary.belowMinCtx++
+					summary.removedNames = append(summary.removedNames, cfgModelName.Value)
+					if optPruneMode == "comment" {
+						if err := pruned.add(cfgModel); err != nil {
+							return tracerr.Wrap(err)
+						}
+					}
 				} else {
-					verboseInfo("remove model: %s", cfgModelName.Value)
+					if modelType, ok := getNodeValue(cfgModel, "type", yaml.ScalarNode); ok && modelType.Value == "embedding" &&
+						lo.SomeBy(embeddingFields, func(key string) bool { return !hasNodeKey(cfgModel, key) }) {
+						if params, err := getModelParameters(cfgModelName.Value); err == nil {
+							if keys := setEmbeddingFields(cfgModel, cfgModelName.Value, params); len(keys) > 0 {
+								verboseModel(logrus.TraceLevel, "embedding", cfgModelName.Value, "set embedding fields of model: %s (%s)", cfgModelName.Value, strings.Join(keys, ", "))
+							}
+						}
+					}
+					setAliasPatch(cfgModel, cfgModelName.Value)
+					if numCtx, err := applyPatchNumCtx(cfgModel, cfgModelName.Value); err != nil {
+						return tracerr.Wrap(err)
+					} else if numCtx > 0 {
+						verboseModel(logrus.TraceLevel, "patch", cfgModelName.Value, "patch num_ctx of model: %s (%d)", cfgModelName.Value, numCtx)
+					}
+					if defaultsNode != nil && optDefExist {
+						if keys := setMissingFields(cfgModel, defaultsNode); len(keys) > 0 {
+							verboseModel(logrus.TraceLevel, "defaults", cfgModelName.Value, "apply defaults to model: %s (%s)", cfgModelName.Value, strings.Join(keys, ", "))
+						}
+					}
+					newModels = append(newModels, cfgModel)
 				}
 			}
 		}
@@ -249,89 +614,309 @@ func process() error {
 	}
 	// add new models
 	{
+		// the progress counts the models to add, the info of the models in the config is fetched on demand only
+		if !optNoAdd {
+			adds := lo.Filter(ollamaModels, func(model string, _ int) bool {
+				return findModelNode(cfgOllamaModels, model) == nil && !lo.Contains(skipAdds, model)
+			})
+			fetchProgress = startProgress("fetching model info", len(adds))
+			defer func() {
+				fetchProgress.clear()
+				fetchProgress = nil
+			}()
+		}
 		for _, model := range ollamaModels {
-			found := false
-			for _, cfgModel := range cfgOllamaModels.Content {
-				cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
-				if ok && cfgModelName.Value == model {
-					found = true
-					break
+			if findModelNode(cfgOllamaModels, model) == nil {
+				if lo.Contains(skipAdds, model) {
+					verboseModel(logrus.TraceLevel, "skip", model, "skip addition of model, not selected: %s", model)
+					continue
 				}
-			}
-			if !found {
-				maxCtxLen, temperature, topP, capabilities, err := getModelParameters(model)
-				if err != nil {
-					tracerr.Wrap(err)
+				if optNoAdd {
+					verboseModel(logrus.TraceLevel, "skip", model, "skip addition of model: %s", model)
+					summary.skippedAdd++
+					continue
+				}
+				params, err := getModelParameters(model)
+				if errors.Is(err, errModelNotFound) {
+					// removed from the server since it was listed
+					verboseModel(logrus.WarnLevel, "skip", model, "model not found on the server, skipped: %s", model)
+					continue
+				} else if err != nil {
+					return withExitCode(exitConnError, err)
+				}
+				if belowMinContext(model) {
+					verboseModel(logrus.TraceLevel, "skip", model, "skip model, context length below %d: %s", optMinCtx, model)
+					summary.belowMinCtx++
+					continue
+				}
+				// the model commented out by --prune-mode comment comes back with its fields
+				if node := pruned.restore(model); node != nil {
+					verboseModel(logrus.DebugLevel, "add", model, "restore pruned model: %s", model)
+					cfgOllamaModels.Content = append(cfgOllamaModels.Content, node)
+					summary.added++
+					summary.addedNames = append(summary.addedNames, model)
+					continue
 				}
 				newNode := &yaml.Node{
 					Kind:    yaml.MappingNode,
 					Content: []*yaml.Node{},
 				}
 				setNodeKeyValue(newNode, yaml.ScalarNode, "name", yaml.ScalarNode, model)
-				if maxCtxLen > 0 {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "max_input_tokens", yaml.ScalarNode, strconv.Itoa(maxCtxLen))
+				if params.maxContextLength > 0 {
+					setNodeKeyValue(newNode, yaml.ScalarNode, "max_input_tokens", yaml.ScalarNode, strconv.Itoa(params.maxContextLength))
 				}
-				if temperature > 0 {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "temperature", yaml.ScalarNode, strconv.FormatFloat(temperature, 'f', 1, 64))
+				if params.maxOutputTokens > 0 {
+					setNodeKeyValue(newNode, yaml.ScalarNode, "max_output_tokens", yaml.ScalarNode, strconv.Itoa(params.maxOutputTokens))
 				}
-				if topP > 0 {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "top_p", yaml.ScalarNode, strconv.FormatFloat(topP, 'f', 1, 64))
+				if params.temperature > 0 && !(optSkipDefParams && params.temperature == ollamaDefaultTemperature) &&
+					!isGlobalValue(cfgDocNode.Content[0], settings, "temperature", params.temperature) {
+					setNodeKeyValue(newNode, yaml.ScalarNode, "temperature", yaml.ScalarNode, strconv.FormatFloat(params.temperature, 'g', -1, 64))
 				}
-				if lo.Contains(capabilities, olmmodel.CapabilityVision) {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "supports_vision", yaml.ScalarNode, "true")
+				if params.topP > 0 && !(optSkipDefParams && params.topP == ollamaDefaultTopP) &&
+					!isGlobalValue(cfgDocNode.Content[0], settings, "top_p", params.topP) {
+					setNodeKeyValue(newNode, yaml.ScalarNode, "top_p", yaml.ScalarNode, strconv.FormatFloat(params.topP, 'g', -1, 64))
 				}
-				if lo.Contains(capabilities, olmmodel.CapabilityTools) {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "supports_function_calling", yaml.ScalarNode, "true")
+				setCapabilityFields(newNode, model, params.capabilities)
+				if optAllParams {
+					if keys := setAllParameters(newNode, params); len(keys) > 0 {
+						verboseModel(logrus.TraceLevel, "params", model, "set all parameters of model: %s (%s)", model, strings.Join(keys, ", "))
+					}
+				}
+				modelType := getModelType(model, params)
+				if modelType != "" {
+					setNodeKeyValue(newNode, yaml.ScalarNode, "type", yaml.ScalarNode, modelType)
+				}
+				if modelType == "embedding" {
+					setEmbeddingFields(newNode, model, params)
 				}
-				if lo.Contains(capabilities, olmmodel.CapabilityThinking) {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "supports_reasoning", yaml.ScalarNode, "true")
+				if newModelPatch != nil {
+					setNodeField(newNode, "patch", copyNode(newModelPatch))
 				}
-				if lo.Contains(capabilities, olmmodel.CapabilityEmbedding) {
-					setNodeKeyValue(newNode, yaml.ScalarNode, "type", yaml.ScalarNode, "embedding")
+				setAliasPatch(newNode, model)
+				if _, err := applyPatchNumCtx(newNode, model); err != nil {
+					return tracerr.Wrap(err)
+				}
+				if defaultsNode != nil {
+					setMissingFields(newNode, defaultsNode)
 				}
 				cfgOllamaModels.Content = append(cfgOllamaModels.Content, newNode)
-				verboseInfo("add model: %s", model)
+				verboseModel(logrus.DebugLevel, "add", model, "add model: %s", model)
+				summary.added++
+				summary.addedNames = append(summary.addedNames, model)
 			}
 		}
 	}
-	// sort the models by name
-	sort.Slice(cfgOllamaModels.Content, func(a, b int) bool {
-		aName, _ := getNodeValue(cfgOllamaModels.Content[a], "name", yaml.ScalarNode)
-		bName, _ := getNodeValue(cfgOllamaModels.Content[b], "name", yaml.ScalarNode)
-		return aName.Value < bName.Value
-	})
-	if optDefModel != "" {
-		var desiredModel string
+	if modelCache != nil {
+		modelCache.save()
+	}
+	fetchProgress.clear()
+	fetchProgress = nil
+	// apply the overrides to new and existing models
+	if len(overrides) > 0 {
 		for _, cfgModel := range cfgOllamaModels.Content {
 			cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
-			if ok {
-				if strings.Contains(cfgModelName.Value, optDefModel) {
-					desiredModel = cfgModelName.Value
-					break
-				}
+			if !ok || isKeptModel(cfgModel, cfgModelName.Value, keepModels) {
+				continue
+			}
+			if keys := applyOverrides(cfgModel, cfgModelName.Value, overrides); len(keys) > 0 {
+				verboseModel(logrus.TraceLevel, "override", cfgModelName.Value, "override model: %s (%s)", cfgModelName.Value, strings.Join(lo.Uniq(keys), ", "))
+				summary.overridden++
 			}
 		}
+	}
+	// sort the models by name or size
+	if err := sortModels(cfgOllamaModels); err != nil {
+		return tracerr.Wrap(err)
+	}
+	pruned.write()
+	if optAnnotateSet {
+		annotateModels(cfgOllamaModels)
+	}
+	// repair the rag models referring to removed models
+	fixRagModel(cfgDocNode.Content[0], cfgOllamaModels, "rag_embedding_model", "embedding", optRagEmbModel, optAutoRagEmb)
+	fixRagModel(cfgDocNode.Content[0], cfgOllamaModels, "rag_reranker_model", "reranker", optRagRerankModel, optAutoRagRerank)
+	// follow the normalization of the current default model, if the normalized one exists
+	if optNormLatest != "" && cfgDefModelNode != nil && cfgDefModelClient == optClientName {
+		name, _ := normalizeLatest(cfgDefModelName)
+		if name != cfgDefModelName && findModelNode(cfgOllamaModels, name) != nil {
+			cfgDefModelName = name
+			cfgDefModelNode.Value = fmt.Sprintf("%s:%s", optClientName, name)
+			verboseInfo("set default model: %s", cfgDefModelNode.Value)
+		}
+	}
+	// follow the rename of the current default model to its alias
+	if cfgDefModelNode != nil && cfgDefModelClient == optClientName && findModelNode(cfgOllamaModels, cfgDefModelName) == nil {
+		if alias := aliasOf(cfgDefModelName); alias != "" && findModelNode(cfgOllamaModels, alias) != nil {
+			cfgDefModelName = alias
+			cfgDefModelNode.Value = fmt.Sprintf("%s:%s", optClientName, alias)
+			verboseInfo("set default model: %s", cfgDefModelNode.Value)
+		}
+	}
+	if optDefModel != "" {
+		desiredModel := findDefaultModel(cfgOllamaModels, optDefModel)
 		if desiredModel != "" {
 			cfgDefModelName = fmt.Sprintf("%s:%s", optClientName, desiredModel)
-			cfgDefModelNode.Value = fmt.Sprintf("%s:%s", optClientName, desiredModel)
+			if cfgDefModelNode == nil {
+				setNodeKeyValue(cfgDocNode.Content[0], yaml.ScalarNode, "model", yaml.ScalarNode, cfgDefModelName)
+			} else {
+				cfgDefModelNode.Value = cfgDefModelName
+			}
 			verboseInfo("set default model: %s", cfgDefModelName)
 		} else {
 			verboseInfo("default model setting skip, model not found: %s", optDefModel)
 		}
+	} else if optAutoDefault != "" {
+		model, reason, err := autoDefaultModel(cfgOllamaModels, ollamaModels, optAutoDefault)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if model != "" {
+			value := fmt.Sprintf("%s:%s", optClientName, model)
+			if cfgDefModelNode == nil {
+				setNodeKeyValue(cfgDocNode.Content[0], yaml.ScalarNode, "model", yaml.ScalarNode, value)
+			} else {
+				cfgDefModelNode.Value = value
+			}
+			cfgDefModelName = model
+			verboseInfo("set default model by %s: %s (%s)", optAutoDefault, value, reason)
+		} else {
+			logrus.Warnf("default model setting skip, no model for auto-default: %s", optAutoDefault)
+		}
+	} else if !optNoFixDefault && cfgDefModelNode != nil && cfgDefModelClient == optClientName &&
+		findModelNode(cfgOllamaModels, cfgDefModelName) == nil {
+		// the default model is removed, fall back to the first chat model
+		fallback := ""
+		for _, cfgModel := range cfgOllamaModels.Content {
+			cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+			modelType, _ := getNodeValue(cfgModel, "type", yaml.ScalarNode)
+			if ok && (modelType == nil || modelType.Value == "chat") {
+				fallback = cfgModelName.Value
+				break
+			}
+		}
+		if fallback != "" {
+			logrus.Warnf("default model not found, replaced: %s -> %s:%s", cfgDefModelNode.Value, optClientName, fallback)
+			cfgDefModelName = fallback
+			cfgDefModelNode.Value = fmt.Sprintf("%s:%s", optClientName, fallback)
+			// drop the quotes needed by an empty model name, e.g. 'ollama:' written by init
+			cfgDefModelNode.Style = 0
+		} else {
+			logrus.Warnf("default model not found and no chat model to replace it: %s", cfgDefModelNode.Value)
+		}
+	}
+
+	// set the top level keys of --set, after the default model so --set model=... wins
+	summary.set = applySettings(cfgDocNode.Content[0], settings)
+	if optStamp {
+		setStamp(cfgOllamaClient, summary.modelChanges() > 0)
+	}
+
+	// remove the client left without models, the other clients are untouched
+	prunedClient := ""
+	if optPruneClients && len(cfgOllamaModels.Content) == 0 {
+		prunedClient = optClientName
+		cfgClients.Content = lo.Filter(cfgClients.Content, func(cn *yaml.Node, _ int) bool { return cn != cfgOllamaClient })
+		verboseInfo("remove client without models: %s", optClientName)
+		if cfgDefModelNode != nil && cfgDefModelClient == optClientName {
+			logrus.Warnf("default model refers to the removed client: %s", cfgDefModelNode.Value)
+		}
+	}
+	logSummary(summary, "%s", summary)
+	lastSummary = summary
+	if optDiffOnly {
+		// print the changes of the models only, nothing is written
+		body, err := json.MarshalIndent(diffModels(modelsBefore, snapshotModels(cfgOllamaModels)), "", "  ")
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		fmt.Printf("%s\n", body)
+		return nil
 	}
 
 	/* -------------------------------------------------------------------------- */
 	/*                                   OUTPUT                                   */
 	/* -------------------------------------------------------------------------- */
-	outbytes, err := yaml.Marshal(cfgDocNode.Content[0])
-	if err != nil {
-		return tracerr.Wrap(err)
+	if !optNoValidate {
+		if err := validateConfig(cfgDocNode.Content[0]); err != nil {
+			return withExitCode(exitInvalid, err)
+		}
+	}
+	var outbytes []byte
+	switch optFormat {
+	case "names":
+		names := []string{}
+		for _, cfgModel := range cfgOllamaModels.Content {
+			if name, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode); ok {
+				names = append(names, name.Value)
+			}
+		}
+		outbytes = []byte(strings.Join(names, "\n"))
+	case "added-names":
+		outbytes = []byte(strings.Join(summary.addedNames, "\n"))
+	default:
+		if outbytes, err = cfg.marshal(); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	// the models of the other formats are checked in the config already
+	if !optNoVerify && isConfigFormat() {
+		if err := verifyOutput(outbytes, prunedClient); err != nil {
+			return withExitCode(exitInvalid, tracerr.Errorf("output verification failed, nothing written, --no-verify to skip: %w", err))
+		}
 	}
 	outstr := strings.TrimSpace(string(outbytes))
-	if optOutFile != "" {
-		verboseInfo("write to: %s", optOutFile)
-		return os.WriteFile(optOutFile, []byte(outstr), 0644)
-	} else {
+	if optCheck {
+		// compare the content only, the comments and the layout are not changes
+		var before, after any
+		if err := yaml.Unmarshal(cfgOrigBody, &before); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err := yaml.Unmarshal(outbytes, &after); err != nil {
+			return tracerr.Wrap(err)
+		}
+		if !reflect.DeepEqual(before, after) {
+			return withExitCode(exitOutOfDate, tracerr.Errorf("config is out of date: %s", summary))
+		}
+		logrus.Infof("config is up to date: %s", optCfgFile)
+		return nil
+	}
+	return writeOutput(outstr, summary)
+}
+
+// writeOutput writes the output to the output file, or stdout if not given or with --dry-run. An unchanged
+// output file is not written, and overwriting it is confirmed by default when run in a terminal.
+func writeOutput(outstr string, summary syncSummary) error {
+	outFile := optOutFile
+	if optDryRun && outFile != "" {
+		verboseInfo("dry run, write skipped: %s", outFile)
+		outFile = ""
+	}
+	if outFile != "" {
+		current, readErr := os.ReadFile(outFile)
+		if readErr == nil && strings.TrimSpace(string(current)) == outstr {
+			verboseInfo("no changes, write skipped: %s", outFile)
+			return nil
+		}
+		// confirm overwriting the config by default when run in a terminal
+		if !optYes && (optConfirm || (readErr == nil && isCharDevice(os.Stdout))) {
+			fmt.Fprintf(os.Stderr, "%s\n", summary)
+			ok, err := confirm(fmt.Sprintf("Apply these %d changes to %s? [y/N] ", summary.changes(), outFile))
+			if err != nil {
+				return tracerr.Wrap(err)
+			}
+			if !ok {
+				logrus.Info("write cancelled")
+				return nil
+			}
+		}
+	}
+	if outFile != "" {
+		verboseInfo("write to: %s", outFile)
+		if err := os.WriteFile(outFile, []byte(outstr+"\n"), 0644); err != nil {
+			return tracerr.Wrap(err)
+		}
+		return runOnChange(outFile, summary)
+	} else if outstr != "" {
 		verboseInfo("write to: stdout")
 		fmt.Printf("%s\n", string(outstr))
 	}
@@ -339,6 +924,224 @@ func process() error {
 	return nil
 }
 
+// findDefaultModel returns the model of the default model argument, the full name with --model-exact, or
+// the model containing it with the one named by it before the tag preferred. Empty if not found.
+func findDefaultModel(models *yaml.Node, query string) string {
+	candidates := []string{}
+	for _, cfgModel := range models.Content {
+		cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+		// rerankers cannot chat
+		if modelType, ok := getNodeValue(cfgModel, "type", yaml.ScalarNode); ok && modelType.Value == "reranker" {
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if optModelExact {
+			if cfgModelName.Value == query {
+				return cfgModelName.Value
+			}
+		} else if strings.Contains(cfgModelName.Value, query) {
+			candidates = append(candidates, cfgModelName.Value)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	chosen := candidates[0]
+	for _, candidate := range candidates {
+		if name, _, _ := strings.Cut(candidate, ":"); name == query {
+			chosen = candidate
+			break
+		}
+	}
+	if len(candidates) > 1 {
+		logrus.Warnf("default model %q is ambiguous, %s is chosen from: %s", query, chosen, strings.Join(candidates, ", "))
+	}
+	return chosen
+}
+
+// isConfigFormat reports whether the output format is the config, not the models of the client.
+func isConfigFormat() bool {
+	return optFormat == "" || optFormat == "yaml" || optFormat == "json"
+}
+
+// marshalConfig marshals the config node in the output format.
+func marshalConfig(node *yaml.Node) ([]byte, error) {
+	switch optFormat {
+	case "", "yaml":
+		if err := applyStyle(documentRoot(node)); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		return marshalYAML(node)
+	case "json":
+		// the whole document in the order of the keys, the comments are dropped
+		return json.MarshalIndent(jsonNode{node}, "", "  ")
+	case "models-yaml":
+		return marshalModelsYAML(node)
+	default:
+		return nil, tracerr.Errorf("unknown output format: %s", optFormat)
+	}
+}
+
+// documentRoot returns the root node of the document node, or the node itself.
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// marshalYAML marshals the value in YAML indented by --indent.
+func marshalYAML(v any) ([]byte, error) {
+	if optIndent < 2 {
+		return nil, tracerr.Errorf("invalid indent, 2 or more is expected: %d", optIndent)
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(optIndent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// findConfigFile finds the aichat config file in the same order as aichat does,
+// $AICHAT_CONFIG_DIR/config.yaml first and then the platform default config directory.
+func findConfigFile() (string, error) {
+	var candidates []string
+	if dir := os.Getenv("AICHAT_CONFIG_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "config.yaml"))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "aichat", "config.yaml"))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			verboseInfo("aichat configuration found: %s", candidate)
+			return candidate, nil
+		}
+	}
+	return "", tracerr.Errorf("aichat configuration not found, tried: %s", strings.Join(candidates, ", "))
+}
+
+func readConfigFile(filename string) ([]byte, error) {
+	if filename == "-" {
+		verboseInfo("aichat configuration read: stdin")
+		body, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		return body, nil
+	}
+	verboseInfo("aichat configuration read: %s", filename)
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return body, nil
+}
+
+// findModelNode returns the model node with the name in the models sequence node, nil if not found.
+func findModelNode(models *yaml.Node, name string) *yaml.Node {
+	for _, cfgModel := range models.Content {
+		cfgModelName, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+		if ok && cfgModelName.Value == name {
+			return cfgModel
+		}
+	}
+	return nil
+}
+
+// loadModelDefaults reads the defaults file of new models into a mapping node,
+// the fields are checked against aichat.ClientModel.
+func loadModelDefaults(filename string) (*yaml.Node, error) {
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var defaults aichat.ClientModel
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&defaults); err != nil && err != io.EOF {
+		return nil, tracerr.Errorf("invalid defaults file (%s): %w", filename, err)
+	}
+	var node yaml.Node
+	if err := node.Encode(defaults); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	verboseInfo("defaults read: %s", filename)
+	return &node, nil
+}
+
+// setMissingFields copies the fields, except name, of the src mapping node which are not present
+// in the dst mapping node, and returns their keys.
+func setMissingFields(dst, src *yaml.Node) []string {
+	keys := []string{}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key := src.Content[i].Value
+		if key == "name" || hasNodeKey(dst, key) {
+			continue
+		}
+		dst.Content = append(dst.Content, copyNode(src.Content[i]), copyNode(src.Content[i+1]))
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// setNodeField sets the value of the key in the mapping node, the key is appended if not present. The comments
+// of the replaced value are kept.
+func setNodeField(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Kind == yaml.ScalarNode && node.Content[i].Value == key {
+			carryComments(value, node.Content[i+1])
+			node.Content[i+1] = value
+			return
+		}
+	}
+	setNodeValue(node, yaml.ScalarNode, key)
+	node.Content = append(node.Content, value)
+}
+
+// hasNodeKey reports whether the mapping node has the key.
+func hasNodeKey(node *yaml.Node, key string) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Kind == yaml.ScalarNode && node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// carryComments copies the head, line and foot comments of the replaced node to the node replacing it,
+// the comments of its own are kept.
+func carryComments(node, replaced *yaml.Node) {
+	if node.HeadComment == "" {
+		node.HeadComment = replaced.HeadComment
+	}
+	if node.LineComment == "" {
+		node.LineComment = replaced.LineComment
+	}
+	if node.FootComment == "" {
+		node.FootComment = replaced.FootComment
+	}
+}
+
+// copyNode returns a deep copy of the node.
+func copyNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	c := *node
+	c.Content = lo.Map(node.Content, func(child *yaml.Node, _ int) *yaml.Node {
+		return copyNode(child)
+	})
+	return &c
+}
+
 func getNodeValue(node *yaml.Node, key string, valueKind yaml.Kind) (*yaml.Node, bool) {
 	for i, childNode := range node.Content {
 		if childNode.Kind == yaml.ScalarNode && childNode.Value == key {
@@ -352,6 +1155,46 @@ func getNodeValue(node *yaml.Node, key string, valueKind yaml.Kind) (*yaml.Node,
 	return nil, false
 }
 
+// splitList splits the comma separated value, the entries are trimmed and the empty ones are dropped,
+// e.g. "llama3, qwen," gives ["llama3", "qwen"].
+func splitList(value string) []string {
+	return lo.Compact(lo.Map(strings.Split(value, ","), func(entry string, _ int) string {
+		return strings.TrimSpace(entry)
+	}))
+}
+
+// isKeptModel reports whether the model node is marked with "keep: true" or its name matches
+// one of the keep patterns. Kept models are neither removed nor changed by the sync.
+func isKeptModel(node *yaml.Node, name string, patterns []string) bool {
+	if keepNode, ok := getNodeValue(node, "keep", yaml.ScalarNode); ok && keepNode.Value == "true" {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := matchModelName("glob", pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchModelName reports whether the model name matches the pattern in the given mode.
+func matchModelName(mode, pattern, name string) (bool, error) {
+	switch mode {
+	case "", "substring":
+		return strings.Contains(name, pattern), nil
+	case "exact":
+		return name == pattern, nil
+	case "glob":
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, tracerr.Errorf("invalid glob pattern (%s): %w", pattern, err)
+		}
+		return matched, nil
+	default:
+		return false, tracerr.Errorf("unknown matching mode: %s", mode)
+	}
+}
+
 func setNodeValue(node *yaml.Node, kind yaml.Kind, value string) {
 	node.Content = append(node.Content, &yaml.Node{Kind: kind, Value: value})
 }
@@ -362,129 +1205,177 @@ func setNodeKeyValue(node *yaml.Node, keyKind yaml.Kind, key string, valueKind y
 }
 
 func initLogrus() {
-	logrus.SetFormatter(&nested.Formatter{
+	// the logs never go to stdout, which is for the output
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(textFormatter())
+	logrus.AddHook(progressHook{})
+}
+
+// textFormatter returns the nested format of the text logs, colored by --color.
+func textFormatter() logrus.Formatter {
+	return &nested.Formatter{
 		HideKeys:        true,
 		TimestampFormat: time.RFC3339,
-	})
+		NoColors:        !useColor(),
+	}
 }
 
-func verboseInfo(format string, args ...any) {
-	if !optQuiet {
-		logrus.Infof(format, args...)
+// setupLogging applies the log flags, it runs before each command as the flags may follow the subcommand.
+func setupLogging(ctx context.Context, _ *cli.Command) (context.Context, error) {
+	if err := checkColorMode(); err != nil {
+		return ctx, tracerr.Wrap(err)
+	}
+	if err := setLogFormat(optLogFormat); err != nil {
+		return ctx, tracerr.Wrap(err)
 	}
+	if err := setLogLevel(); err != nil {
+		return ctx, tracerr.Wrap(err)
+	}
+	return ctx, nil
 }
 
-func getOllamaModels() ([]string, error) {
-	resp, err := ollamaClient.List(context.Background())
-	if err != nil {
-		return []string{}, tracerr.Wrap(err)
+// setLogFormat switches the log format, text is the nested format set by initLogrus.
+func setLogFormat(format string) error {
+	switch format {
+	case "", "text":
+		// --color is known now
+		logrus.SetFormatter(textFormatter())
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339})
+	default:
+		return tracerr.Errorf("unknown log format: %s", format)
 	}
-	models := lo.Map(resp.Models, func(model olmapi.ListModelResponse, _ int) string {
-		return model.Name
-	})
-	return models, nil
+	return nil
 }
 
-func getModelParameters(model string) (int, float64, float64, []olmmodel.Capability, error) {
-	maxContextLength := -1
-	temperature := -1.0
-	topP := -1.0
-
-	info, err := getModelInfo(model)
+// setLogLevel sets the log level by --log-level, or by the count of -v: info for the warnings and the
+// summary, debug for the actions on the models and the progress (-v), and trace for the decisions and the
+// requests (-vv). --debug and --quiet take precedence for compatibility.
+func setLogLevel() error {
+	level, err := logrus.ParseLevel(optLogLevel)
 	if err != nil {
-		return maxContextLength, temperature, topP, nil, tracerr.Wrap(err)
+		return tracerr.Wrap(err)
 	}
-	// find the max context length
-	for key, value := range info.ModelInfo {
-		if strings.Contains(key, ".context_length") {
-			maxContextLength = int(value.(float64))
-			break
-		}
+	if optVerbose > 0 {
+		level = min(logrus.InfoLevel+logrus.Level(optVerbose), logrus.TraceLevel)
 	}
-	// find temperature and top_p
-	parameters := strings.SplitSeq(info.Parameters, "\n")
-	for parameter := range parameters {
-		paramKV := strings.Fields(parameter)
-		if len(paramKV) > 1 {
-			paramValue := strings.TrimSpace(paramKV[1])
-			if strings.Contains(paramKV[0], "temperature") {
-				f, err := strconv.ParseFloat(paramValue, 64)
-				if err == nil {
-					temperature = f
-				}
-			}
-			if strings.Contains(paramKV[0], "top_p") {
-				f, err := strconv.ParseFloat(paramValue, 64)
-				if err == nil {
-					topP = f
-				}
-			}
-		}
+	if optDebug {
+		level = logrus.TraceLevel
+	} else if optQuiet {
+		level = logrus.ErrorLevel
 	}
-	return maxContextLength, temperature, topP, info.Capabilities, nil
+	logrus.SetLevel(level)
+	return nil
 }
 
-func getModelInfo(model string) (*olmapi.ShowResponse, error) {
-	resp, err := ollamaClient.Show(context.Background(), &olmapi.ShowRequest{Model: model})
-	if err != nil {
-		return nil, tracerr.Wrap(err)
-	}
-	return resp, nil
+// verboseInfo logs the high-level progress, shown with -v.
+func verboseInfo(format string, args ...any) {
+	logrus.Debugf(format, args...)
 }
 
-/* -------------------------------------------------------------------------- */
-/*                     OLLAMA CLIENT WITH API KEY SUPPORT                     */
-/* -------------------------------------------------------------------------- */
+// verboseDebug logs the details, e.g. the decision on each model and the requests, shown with -vv.
+func verboseDebug(format string, args ...any) {
+	logrus.Tracef(format, args...)
+}
 
-// apiKeyTransport adds the API_KEY header to every request.
-type apiKeyTransport struct {
-	rt     http.RoundTripper // the underlying transport
-	apiKey string            // the value you want to send
+// verboseModel logs the action on the model at the level, with the fields action, model and client in json log format.
+func verboseModel(level logrus.Level, action, model, format string, args ...any) {
+	if optLogFormat == "json" {
+		logrus.WithFields(logrus.Fields{
+			"action": action,
+			"model":  model,
+			"client": optClientName,
+		}).Logf(level, format, args...)
+	} else {
+		logrus.StandardLogger().Logf(level, format, args...)
+	}
 }
 
-// RoundTrip implements http.RoundTripper.
-func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Clone the request so we don't mutate the caller's request
-	// (recommended by the net/http docs for RoundTripper wrappers).
-	req2 := req.Clone(req.Context())
+// logSummary logs the summary of the sync at info level, with the counts and the client as the fields in json
+// log format.
+func logSummary(summary syncSummary, format string, args ...any) {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if optLogFormat == "json" {
+		entry = entry.WithFields(logrus.Fields{
+			"client":            optClientName,
+			"added":             summary.added,
+			"removed":           summary.removed,
+			"excluded":          summary.excluded,
+			"below_min_context": summary.belowMinCtx,
+			"overridden":        summary.overridden,
+		})
+	}
+	entry.Infof(format, args...)
+}
 
-	// Add the header – you can use Add, Set or Direct assignment.
-	req2.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.apiKey))
+// isRerankerModel reports whether the model is a reranker, e.g. bge-reranker or mxbai-rerank.
+func isRerankerModel(model string, params *modelParameters) bool {
+	for _, pattern := range splitList(optReranker) {
+		if matched, _ := matchModelName("glob", pattern, model); matched {
+			return true
+		}
+	}
+	return params.reranker || strings.Contains(strings.ToLower(model), "rerank")
+}
 
-	// Pass the request on to the wrapped RoundTripper.
-	return t.rt.RoundTrip(req2)
+// getModelType returns the aichat model type by the capabilities, empty for chat models unless --emit-type is set.
+// Rerankers are detected by the name, the model info or --reranker.
+func getModelType(model string, params *modelParameters) string {
+	if isRerankerModel(model, params) {
+		return "reranker"
+	}
+	capabilities := params.capabilities
+	isEmbedding := lo.Contains(capabilities, olmmodel.CapabilityEmbedding)
+	if !optEmitType {
+		return lo.Ternary(isEmbedding, "embedding", "")
+	}
+	if isEmbedding && lo.Contains(capabilities, olmmodel.CapabilityCompletion) {
+		verboseModel(logrus.WarnLevel, "type", model, "model has both embedding and chat capabilities, type chat is used: %s", model)
+		return "chat"
+	}
+	return lo.Ternary(isEmbedding, "embedding", "chat")
 }
 
-func createOllamaClient(apiBase, apiKey string) (*api.Client, error) {
-	// Use http.DefaultTransport if you don't need custom TLS settings.
-	// If you do need TLS or proxy config, create your own *http.Transport.
-	base := http.DefaultTransport
+// embeddingFields are the fields of embedding models set by setEmbeddingFields.
+var embeddingFields = []string{"max_tokens_per_chunk", "default_chunk_size", "max_batch_size"}
 
-	// Wrap it
-	wrapped := &apiKeyTransport{
-		rt:     base,
-		apiKey: apiKey,
+// setEmbeddingFields sets the missing embedding fields of the model, derived from the context length unless
+// given by the flags, and returns the keys set.
+func setEmbeddingFields(node *yaml.Node, model string, params *modelParameters) []string {
+	verboseDebug("embedding length of %s: %d", model, params.embeddingLength)
+	maxTokens := optEmbMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = params.maxContextLength
 	}
-
-	httpClient := &http.Client{
-		Transport: wrapped,
+	chunkSize := optEmbChunk
+	if chunkSize <= 0 && params.maxContextLength > 0 {
+		chunkSize = min(1000, params.maxContextLength/2)
 	}
-
-	var client *api.Client
-	if apiBase != "" {
-		// remove the path
-		u, err := url.Parse(apiBase)
-		if err != nil {
-			return nil, tracerr.Wrap(err)
-		}
-		u.Path = ""
-		client = olmapi.NewClient(u, httpClient)
-	} else {
-		c, err := olmapi.ClientFromEnvironment()
-		if err != nil {
-			return nil, tracerr.Wrap(err)
+	values := map[string]int{
+		"max_tokens_per_chunk": maxTokens,
+		"default_chunk_size":   chunkSize,
+		"max_batch_size":       optEmbBatch,
+	}
+	keys := []string{}
+	for _, key := range embeddingFields {
+		// the values set by hand win
+		if values[key] > 0 && !hasNodeKey(node, key) {
+			setNodeKeyValue(node, yaml.ScalarNode, key, yaml.ScalarNode, strconv.Itoa(values[key]))
+			keys = append(keys, key)
 		}
-		client = c
 	}
-	return client, nil
+	return keys
+}
+
+// belowMinContext reports whether the context length of the model is below --min-context,
+// models with unknown context length are kept unless --min-context-strict is set.
+func belowMinContext(model string) bool {
+	if optMinCtx <= 0 {
+		return false
+	}
+	params, err := getModelParameters(model)
+	if err != nil || params.maxContextLength < 0 {
+		return optMinCtxStr
+	}
+	return params.maxContextLength < optMinCtx
 }
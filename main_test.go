@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	olmapi "github.com/ollama/ollama/api"
+	olmmodel "github.com/ollama/ollama/types/model"
+	"gopkg.in/yaml.v3"
+)
+
+// TestMain runs aichatconf in the child process started by runMain, or else the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("AICHATCONF_TEST_MAIN") == "1" {
+		main()
+		os.Exit(exitOK)
+	}
+	os.Exit(m.Run())
+}
+
+// runResult is the outcome of aichatconf run by runMain.
+type runResult struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// runMain runs aichatconf with the arguments and the stdin in a child process of the test binary, the home,
+// config and cache directories are of the test so nothing of the user is read or written.
+func runMain(t *testing.T, stdin string, args ...string) runResult {
+	t.Helper()
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(),
+		"AICHATCONF_TEST_MAIN=1",
+		"NO_COLOR=1",
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+filepath.Join(home, "config"),
+		"XDG_CACHE_HOME="+filepath.Join(home, "cache"),
+		"AICHAT_CONFIG_DIR=",
+	)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("run aichatconf: %v", err)
+	}
+	return runResult{stdout: stdout.String(), stderr: stderr.String(), code: cmd.ProcessState.ExitCode()}
+}
+
+// writeFile writes the content to the file of the name in a temporary directory of the test.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// mockModel is a model of ollamaMock.
+type mockModel struct {
+	name         string
+	family       string
+	contextLen   int
+	parameters   string // parameters of the modelfile, e.g. "temperature 0.8"
+	capabilities []string
+	digest       string
+	size         int64
+	modifiedAt   time.Time
+	showStatus   int // status of show if failed, e.g. 404 of the model removed since listed
+}
+
+// ollamaMock is an ollama server of the models, it records the authorization headers of the requests.
+type ollamaMock struct {
+	*httptest.Server
+	models []mockModel
+
+	mu    sync.Mutex
+	auths []http.Header
+}
+
+// newOllamaMock starts an ollama server of the models, closed at the end of the test.
+func newOllamaMock(t *testing.T, models ...mockModel) *ollamaMock {
+	t.Helper()
+	mock := &ollamaMock{models: models}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", mock.tags)
+	mux.HandleFunc("POST /api/show", mock.show)
+	mux.HandleFunc("GET /api/version", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"version": "0.11.4"})
+	})
+	mock.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mock.mu.Lock()
+		mock.auths = append(mock.auths, r.Header.Clone())
+		mock.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(mock.Close)
+	return mock
+}
+
+// headers returns the headers of the requests received.
+func (mock *ollamaMock) headers() []http.Header {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return append([]http.Header{}, mock.auths...)
+}
+
+func (mock *ollamaMock) tags(w http.ResponseWriter, _ *http.Request) {
+	resp := olmapi.ListResponse{Models: []olmapi.ListModelResponse{}}
+	for _, model := range mock.models {
+		resp.Models = append(resp.Models, olmapi.ListModelResponse{
+			Name:       model.name,
+			Model:      model.name,
+			ModifiedAt: model.modifiedAt,
+			Size:       model.size,
+			Digest:     model.digest,
+			Details:    olmapi.ModelDetails{Family: model.family},
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (mock *ollamaMock) show(w http.ResponseWriter, r *http.Request) {
+	var req olmapi.ShowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	name := req.Model
+	if name == "" {
+		name = req.Name
+	}
+	for _, model := range mock.models {
+		if model.name != name && model.name != name+":latest" {
+			continue
+		}
+		if model.showStatus != 0 {
+			writeJSON(w, model.showStatus, map[string]string{"error": http.StatusText(model.showStatus)})
+			return
+		}
+		resp := olmapi.ShowResponse{
+			Parameters: model.parameters,
+			Details:    olmapi.ModelDetails{Family: model.family},
+			ModelInfo: map[string]any{
+				"general.architecture":           model.family,
+				model.family + ".context_length": model.contextLen,
+			},
+			ModifiedAt: model.modifiedAt,
+		}
+		for _, capability := range model.capabilities {
+			resp.Capabilities = append(resp.Capabilities, olmmodel.Capability(capability))
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("model '%s' not found", name)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// testModels are the models of the mock server of the tests.
+var testModels = []mockModel{
+	{name: "llama3:latest", family: "llama", contextLen: 8192, parameters: "temperature 0.8\ntop_p 0.9",
+		capabilities: []string{"completion", "tools"}, digest: "aaa", size: 4_700_000_000},
+	{name: "qwen2.5:14b", family: "qwen2", contextLen: 32768, parameters: "temperature 0.05\ntop_p 0.95",
+		capabilities: []string{"completion", "tools"}, digest: "bbb", size: 9_000_000_000},
+	{name: "nomic-embed-text:latest", family: "nomic-bert", contextLen: 2048,
+		capabilities: []string{"embedding"}, digest: "ccc", size: 270_000_000},
+}
+
+// ollamaConfig returns a config of an ollama client of the server, followed by the extra lines of the client.
+func ollamaConfig(url string, extra ...string) string {
+	lines := []string{
+		"model: ollama:llama3:latest",
+		"clients:",
+		"  - type: ollama",
+		"    name: ollama",
+		"    api_base: " + url + "/v1",
+	}
+	for _, line := range extra {
+		lines = append(lines, "    "+line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// decodeConfig decodes the output of a sync to a map, the test fails if it is not of a single YAML document.
+func decodeConfig(t *testing.T, output string) map[string]any {
+	t.Helper()
+	var cfg map[string]any
+	decoder := yaml.NewDecoder(strings.NewReader(output))
+	if err := decoder.Decode(&cfg); err != nil {
+		t.Fatalf("output is not YAML: %v\n%s", err, output)
+	}
+	if err := decoder.Decode(&map[string]any{}); err == nil {
+		t.Fatalf("output is not a single YAML document:\n%s", output)
+	}
+	return cfg
+}
+
+// clientModelNames returns the names of the models of the client in the decoded config.
+func clientModelNames(t *testing.T, cfg map[string]any, client string) []string {
+	t.Helper()
+	clients, _ := cfg["clients"].([]any)
+	for _, c := range clients {
+		c, _ := c.(map[string]any)
+		if c["name"] != client {
+			continue
+		}
+		names := []string{}
+		models, _ := c["models"].([]any)
+		for _, m := range models {
+			m, _ := m.(map[string]any)
+			names = append(names, fmt.Sprint(m["name"]))
+		}
+		return names
+	}
+	t.Fatalf("client not found: %s", client)
+	return nil
+}
+
+func TestConfigFromStdin(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	body := "---\n" + ollamaConfig(mock.URL)
+
+	res := runMain(t, body, "-c", "-", "-vv")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	if !strings.HasPrefix(res.stdout, "---\n") {
+		t.Errorf("--- not kept:\n%s", res.stdout)
+	}
+	// the logs are of stderr only, stdout is the config
+	if !strings.Contains(res.stderr, "aichat configuration read: stdin") {
+		t.Errorf("verbose log not on stderr:\n%s", res.stderr)
+	}
+	if strings.Contains(res.stdout, "aichat configuration read") || strings.Contains(res.stdout, "summary") {
+		t.Errorf("log on stdout:\n%s", res.stdout)
+	}
+	names := clientModelNames(t, decodeConfig(t, res.stdout), "ollama")
+	if len(names) != len(testModels) {
+		t.Errorf("models of the output: %v", names)
+	}
+
+	// -o writes the file, nothing is on stdout
+	out := filepath.Join(t.TempDir(), "config.yaml")
+	res = runMain(t, body, "-c", "-", "-o", out)
+	if res.code != exitOK || res.stdout != "" {
+		t.Fatalf("exit code %d, stdout %q: %s", res.code, res.stdout, res.stderr)
+	}
+	if written, err := os.ReadFile(out); err != nil || !strings.HasPrefix(string(written), "---\n") {
+		t.Errorf("output file: %v\n%s", err, written)
+	}
+
+	res = runMain(t, "", "-c", "-")
+	if res.code != exitConfigError || !strings.Contains(res.stderr, "empty config file") {
+		t.Errorf("empty stdin, exit code %d: %s", res.code, res.stderr)
+	}
+}
+
+func TestExcludeWithSpaces(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	cfgFile := writeFile(t, "config.yaml", ollamaConfig(mock.URL))
+
+	res := runMain(t, "", "-c", cfgFile, "--exclude", "qwen2.5, nomic ,")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	names := clientModelNames(t, decodeConfig(t, res.stdout), "ollama")
+	if strings.Join(names, ",") != "llama3:latest" {
+		t.Errorf("models of the output: %v", names)
+	}
+}
+
+func TestKeepModelAbsentFromServer(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	cfgFile := writeFile(t, "config.yaml", ollamaConfig(mock.URL,
+		"models:",
+		"  - name: cloud-proxy:latest",
+		"    max_input_tokens: 128000",
+		"  - name: pinned:7b",
+		"    keep: true",
+		"  - name: gone:1b",
+	))
+
+	res := runMain(t, "", "-c", cfgFile, "--keep", "cloud-*")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	cfg := decodeConfig(t, res.stdout)
+	names := clientModelNames(t, cfg, "ollama")
+	for _, name := range []string{"cloud-proxy:latest", "pinned:7b", "llama3:latest"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("model not in the output: %s: %v", name, names)
+		}
+	}
+	if slices.Contains(names, "gone:1b") {
+		t.Errorf("obsolete model not removed: %v", names)
+	}
+	// the kept model is untouched
+	models := cfg["clients"].([]any)[0].(map[string]any)["models"].([]any)
+	if kept := models[0].(map[string]any); kept["max_input_tokens"] != 128000 || len(kept) != 2 {
+		t.Errorf("kept model changed: %v", kept)
+	}
+}
+
+func TestSamplingParametersRoundTrip(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	cfgFile := writeFile(t, "config.yaml", ollamaConfig(mock.URL))
+
+	res := runMain(t, "", "-c", cfgFile, "--include", "qwen2.5:14b,llama3")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	for _, line := range []string{"temperature: 0.05", "top_p: 0.95", "temperature: 0.8", "top_p: 0.9"} {
+		if !strings.Contains(res.stdout, line+"\n") {
+			t.Errorf("%s not in the output:\n%s", line, res.stdout)
+		}
+	}
+	for _, model := range decodeConfig(t, res.stdout)["clients"].([]any)[0].(map[string]any)["models"].([]any) {
+		if model := model.(map[string]any); model["name"] == "qwen2.5:14b" && (model["temperature"] != 0.05 || model["top_p"] != 0.95) {
+			t.Errorf("sampling parameters: %v", model)
+		}
+	}
+}
+
+func TestPruneClients(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := `model: remote:gpt-4o
+clients:
+  # the local server
+  - type: ollama
+    name: ollama
+    api_base: ` + mock.URL + `/v1
+    models:
+      - name: llama3:latest
+  # left empty, not synced
+  - type: ollama
+    name: empty
+    api_base: ` + mock.URL + `/v1
+    models: []
+  # the remote server
+  - type: openai
+    name: remote
+    models:
+      - name: gpt-4o
+`
+	cfgFile := writeFile(t, "config.yaml", config)
+
+	res := runMain(t, "", "-c", cfgFile, "--client", "ollama", "--prune-clients", "--exclude", "*", "--exclude-mode", "glob")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	var names []string
+	for _, client := range decodeConfig(t, res.stdout)["clients"].([]any) {
+		names = append(names, client.(map[string]any)["name"].(string))
+	}
+	if strings.Join(names, ",") != "empty,remote" {
+		t.Errorf("clients of the output: %v", names)
+	}
+	for _, comment := range []string{"# left empty, not synced", "# the remote server"} {
+		if !strings.Contains(res.stdout, comment) {
+			t.Errorf("comment lost: %s\n%s", comment, res.stdout)
+		}
+	}
+	if strings.Contains(res.stdout, "# the local server") {
+		t.Errorf("comment of the removed client kept:\n%s", res.stdout)
+	}
+
+	// the client keeping models is not removed
+	res = runMain(t, "", "-c", cfgFile, "--client", "ollama", "--prune-clients")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	if names := clientModelNames(t, decodeConfig(t, res.stdout), "ollama"); len(names) != len(testModels) {
+		t.Errorf("models of the output: %v", names)
+	}
+}
+
+func TestSyncIdempotent(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := `# aichat config
+model: ollama:llama3:latest
+temperature: 0.7
+clients:
+  - type: ollama
+    name: ollama
+    api_base: ` + mock.URL + `/v1
+    models:
+      # pinned by hand
+      - name: qwen2.5:14b
+        max_input_tokens: 16384 # smaller for the memory
+      - name: obsolete:1b
+`
+	first := runMain(t, "", "-c", writeFile(t, "config.yaml", config))
+	if first.code != exitOK {
+		t.Fatalf("exit code %d: %s", first.code, first.stderr)
+	}
+	second := runMain(t, "", "-c", writeFile(t, "config.yaml", first.stdout))
+	if second.code != exitOK {
+		t.Fatalf("exit code %d: %s", second.code, second.stderr)
+	}
+	if first.stdout != second.stdout {
+		t.Errorf("second sync changed the output:\n%s\n---- second ----\n%s", first.stdout, second.stdout)
+	}
+	if !strings.Contains(second.stderr, "summary: 0 added, 0 removed") {
+		t.Errorf("second sync not a no-op:\n%s", second.stderr)
+	}
+}
+
+func TestStdoutOnlyYAML(t *testing.T) {
+	gone := mockModel{name: "gone:1b", family: "llama", contextLen: 1024, showStatus: http.StatusNotFound}
+	mock := newOllamaMock(t, append(testModels, gone)...)
+	closed := httptest.NewServer(nil)
+	closed.Close()
+	twoClients := ollamaConfig(mock.URL) + "  - type: ollama\n    name: down\n    api_base: " + closed.URL + "/v1\n"
+
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		code   int
+	}{
+		{name: "model not found by show", config: ollamaConfig(mock.URL), args: []string{"-vv"}, code: exitOK},
+		{name: "failed client of all", config: twoClients, args: []string{"--all-ollama", "-vv"}, code: exitConnError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runMain(t, "", append([]string{"-c", writeFile(t, "config.yaml", tt.config)}, tt.args...)...)
+			if res.code != tt.code {
+				t.Fatalf("exit code %d, expected %d: %s", res.code, tt.code, res.stderr)
+			}
+			if !strings.Contains(res.stderr, "summary") {
+				t.Errorf("summary not on stderr:\n%s", res.stderr)
+			}
+			// every line of stdout is of the config
+			var cfg yaml.Node
+			if err := yaml.Unmarshal([]byte(res.stdout), &cfg); err != nil || len(cfg.Content) == 0 {
+				t.Fatalf("stdout is not YAML: %v\n%s", err, res.stdout)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(res.stdout), "\n") {
+				if strings.Contains(line, "[WARN]") || strings.Contains(line, "[ERRO]") || strings.Contains(line, "summary") {
+					t.Errorf("log on stdout: %s", line)
+				}
+			}
+			if names := clientModelNames(t, decodeConfig(t, res.stdout), "ollama"); len(names) != len(testModels) {
+				t.Errorf("models of the output: %v", names)
+			}
+		})
+	}
+}
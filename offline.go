@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	olmapi "github.com/ollama/ollama/api"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// modelsDump is the model inventory of ollama for the offline mode, the output of /api/tags
+// and optionally /api/show of each model.
+type modelsDump struct {
+	Tags olmapi.ListResponse             `json:"tags"`
+	Show map[string]*olmapi.ShowResponse `json:"show,omitempty"`
+}
+
+// modelsFileSource is the model source of a models dump file, the same as ollama but offline.
+type modelsFileSource struct {
+	dump    modelsDump
+	names   map[string]string // normalized name to the name in the dump
+	details map[string]modelDetails
+}
+
+func (s *modelsFileSource) name() string {
+	return "models-file"
+}
+
+// listModels returns the models in the tags of the dump.
+func (s *modelsFileSource) listModels() ([]ollamaModel, error) {
+	listed, err := getListedModels(&s.dump.Tags)
+	if err != nil {
+		return []ollamaModel{}, tracerr.Wrap(err)
+	}
+	s.names = map[string]string{}
+	s.details = map[string]modelDetails{}
+	for _, model := range listed {
+		s.names[model.name] = model.listedName
+		s.details[model.name] = model.details
+	}
+	return listed, nil
+}
+
+func (s *modelsFileSource) modelDetails(model string) (modelDetails, bool) {
+	details, ok := s.details[model]
+	return details, ok
+}
+
+// showModel returns the parameters of the model from the show response in the dump,
+// the parameters are unknown if it is absent.
+func (s *modelsFileSource) showModel(model string) (*modelParameters, error) {
+	name := model
+	if n, ok := s.names[model]; ok {
+		name = n
+	}
+	info, ok := s.dump.Show[name]
+	if !ok || info == nil {
+		verboseDebug("model info not found in models file, name only: %s", model)
+		return newModelParameters(), nil
+	}
+	return parseShowResponse(info), nil
+}
+
+// createModelsFileSource reads the models dump file.
+func createModelsFileSource(filename string) (*modelsFileSource, error) {
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	src := &modelsFileSource{}
+	if err := json.Unmarshal(body, &src.dump); err != nil {
+		return nil, tracerr.Errorf("invalid models file (%s): %w", filename, err)
+	}
+	verboseInfo("models file read: %s", filename)
+	return src, nil
+}
+
+// dumpModels writes the models dump of ollama to the output file or stdout, for the offline mode on another machine.
+func dumpModels() error {
+	client, err := dumpClient()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	apiBase, apiKey, err := getAPIBaseKey(client)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	proxyURL, err := getProxyURL(client)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	c, err := createOllamaClient(apiBase, apiKey, getTLSOptions(client), proxyURL)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	resp, err := c.List(context.Background())
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	dump := modelsDump{Tags: *resp, Show: map[string]*olmapi.ShowResponse{}}
+	for _, model := range resp.Models {
+		info, err := c.Show(context.Background(), &olmapi.ShowRequest{Model: model.Name})
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		// the tensors are large and not used
+		info.Tensors = nil
+		dump.Show[model.Name] = info
+		verboseDebug("model dumped: %s", model.Name)
+	}
+	verboseInfo("models dumped: %d", len(resp.Models))
+
+	body, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if optOutFile != "" {
+		verboseInfo("write to: %s", optOutFile)
+		return os.WriteFile(optOutFile, body, 0644)
+	}
+	verboseInfo("write to: stdout")
+	fmt.Printf("%s\n", string(body))
+	return nil
+}
+
+// dumpClient returns the client of the config to connect to as the sync does, or an empty client without a
+// config, e.g. on the ollama host, connecting by the options and the environment only.
+func dumpClient() (*yaml.Node, error) {
+	if optCfgFile == "" {
+		cfgFile, err := findConfigFile()
+		if err != nil {
+			verboseInfo("no config, connect by the options")
+			return &yaml.Node{}, nil
+		}
+		optCfgFile = cfgFile
+	}
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err := cfg.findClient(); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return cfg.client, nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	olmapi "github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+)
+
+const rankPoolingType = 4
+
+// ollamaSource is the model source of ollama, the models of several hosts are merged.
+type ollamaSource struct {
+	hosts   []ollamaHost
+	owners  map[string]*olmapi.Client // first host having the model
+	details map[string]modelDetails   // details of the listed models, the digests are for the show cache
+}
+
+type ollamaHost struct {
+	client *olmapi.Client
+	host   string
+}
+
+// createOllamaSource creates the source of ollama, the first api_base is the client itself
+// and the others are extra hosts.
+func createOllamaSource(apiBases []string, apiKey string, tlsOpts tlsOptions, proxyURL *url.URL) (*ollamaSource, error) {
+	src := &ollamaSource{}
+	for _, apiBase := range apiBases {
+		c, err := createOllamaClient(apiBase, apiKey, tlsOpts, proxyURL)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		host := redactURL(apiBase)
+		if apiBase == "" {
+			host = envconfig.Host().String()
+		}
+		src.hosts = append(src.hosts, ollamaHost{client: c, host: host})
+	}
+	return src, nil
+}
+
+func (*ollamaSource) name() string {
+	return "ollama"
+}
+
+// listModels returns the union of the models of the hosts, a host not answering is skipped
+// unless all of them fail.
+func (s *ollamaSource) listModels() ([]ollamaModel, error) {
+	models := []ollamaModel{}
+	s.owners = map[string]*olmapi.Client{}
+	s.details = map[string]modelDetails{}
+	var lastErr error
+	for _, host := range s.hosts {
+		listModels := getOllamaModels
+		if optRunningOnly {
+			listModels = getRunningOllamaModels
+		}
+		listed, err := listModels(host.client)
+		if err != nil {
+			if len(s.hosts) == 1 {
+				return []ollamaModel{}, tracerr.Wrap(err)
+			}
+			logrus.Warnf("ollama not available, skipped: %s: %v", host.host, err)
+			lastErr = err
+			continue
+		}
+		for _, model := range listed {
+			if _, ok := s.owners[model.name]; ok {
+				continue
+			}
+			s.details[model.name] = model.details
+			s.owners[model.name] = host.client
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 && lastErr != nil {
+		return []ollamaModel{}, tracerr.Wrap(lastErr)
+	}
+	return models, nil
+}
+
+func (s *ollamaSource) modelDetails(model string) (modelDetails, bool) {
+	details, ok := s.details[model]
+	return details, ok
+}
+
+// showModel returns the parameters of the model from the show cache, or the show response if not cached.
+func (s *ollamaSource) showModel(model string) (*modelParameters, error) {
+	digest := s.details[model].digest
+	if modelCache != nil && !optRefreshCache {
+		if params, ok := modelCache.get(model, digest); ok {
+			verboseDebug("show cache hit: %s", model)
+			return params, nil
+		}
+	}
+	c, ok := s.owners[model]
+	if !ok {
+		c = s.hosts[0].client
+	}
+	info, err := getModelInfo(c, model)
+	if err != nil {
+		var statusErr olmapi.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return newModelParameters(), tracerr.Errorf("%w: %s", errModelNotFound, model)
+		}
+		return newModelParameters(), tracerr.Wrap(err)
+	}
+	params := parseShowResponse(info)
+	if modelCache != nil {
+		modelCache.put(model, digest, params)
+	}
+	return params, nil
+}
+
+// getContextLength returns the context length of the model in the model info, -1 if unknown. The key of the
+// --context-key-prefix, or the architecture of the model, is preferred over the ones of auxiliary towers,
+// e.g. llama.context_length over clip.vision.context_length.
+func getContextLength(info *olmapi.ShowResponse) int {
+	prefix := optCtxKeyPrefix
+	if prefix == "" {
+		if arch, ok := info.ModelInfo["general.architecture"].(string); ok {
+			prefix = arch
+		} else {
+			prefix = info.Details.Family
+		}
+	}
+	if value, ok := info.ModelInfo[prefix+".context_length"].(float64); ok {
+		return int(value)
+	}
+	keys := lo.Filter(lo.Keys(info.ModelInfo), func(key string, _ int) bool {
+		return strings.HasSuffix(key, ".context_length")
+	})
+	if len(keys) == 0 {
+		return -1
+	}
+	// map order is random, the text one is taken before the vision one for the same result in every run
+	sort.Slice(keys, func(a, b int) bool {
+		aVision, bVision := strings.Contains(keys[a], "vision"), strings.Contains(keys[b], "vision")
+		if aVision != bVision {
+			return bVision
+		}
+		return keys[a] < keys[b]
+	})
+	if value, ok := info.ModelInfo[keys[0]].(float64); ok {
+		return int(value)
+	}
+	return -1
+}
+
+// parseShowResponse returns the parameters of the model in the show response.
+func parseShowResponse(info *olmapi.ShowResponse) *modelParameters {
+	params := newModelParameters()
+	params.maxContextLength = getContextLength(info)
+	// find the embedding dimension
+	for key, value := range info.ModelInfo {
+		if strings.HasSuffix(key, ".embedding_length") {
+			if f, ok := value.(float64); ok {
+				params.embeddingLength = int(f)
+			}
+			break
+		}
+	}
+	// rerankers are pooled by rank, LLAMA_POOLING_TYPE_RANK of llama.cpp
+	for key, value := range info.ModelInfo {
+		if strings.HasSuffix(key, ".pooling_type") {
+			if f, ok := value.(float64); ok && f == rankPoolingType {
+				params.reranker = true
+			}
+			break
+		}
+	}
+	// find temperature, top_p and num_predict
+	parameters := strings.SplitSeq(info.Parameters, "\n")
+	for parameter := range parameters {
+		paramKV := strings.Fields(parameter)
+		if len(paramKV) > 1 {
+			paramValue := strings.TrimSpace(paramKV[1])
+			if strings.Contains(paramKV[0], "temperature") {
+				f, err := strconv.ParseFloat(paramValue, 64)
+				if err == nil {
+					params.temperature = f
+				}
+			}
+			if strings.Contains(paramKV[0], "top_p") {
+				f, err := strconv.ParseFloat(paramValue, 64)
+				if err == nil {
+					params.topP = f
+				}
+			}
+			// -1 is unlimited and -2 is to fill the context, both are left unknown
+			if paramKV[0] == "num_predict" {
+				n, err := strconv.Atoi(paramValue)
+				if err == nil && n > 0 {
+					params.maxOutputTokens = n
+				}
+			}
+			// keep all of them for --all-params, a parameter like stop may be repeated
+			value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(parameter), paramKV[0]))
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			if params.parameters == nil {
+				params.parameters = map[string][]string{}
+			}
+			params.parameters[paramKV[0]] = append(params.parameters[paramKV[0]], value)
+		}
+	}
+	params.capabilities = info.Capabilities
+	return params
+}
+
+// ollamaModel is a model in the list of the server, of ollama under the normalized name. The details are known
+// of the sources listing them only, e.g. ollama.
+type ollamaModel struct {
+	name       string
+	listedName string // name in the list, before the normalization of the :latest tag
+	details    modelDetails
+}
+
+// modelNames returns the names of the models in order.
+func modelNames(models []ollamaModel) []string {
+	return lo.Map(models, func(model ollamaModel, _ int) string { return model.name })
+}
+
+// getListedModels returns the models in the list response under the normalized names, in the listed order.
+func getListedModels(resp *olmapi.ListResponse) ([]ollamaModel, error) {
+	models := []ollamaModel{}
+	for _, model := range resp.Models {
+		name, err := normalizeLatest(model.Name)
+		if err != nil {
+			return []ollamaModel{}, tracerr.Wrap(err)
+		}
+		// the same model may be listed with and without the :latest tag, keep the first one
+		if lo.ContainsBy(models, func(m ollamaModel) bool { return m.name == name }) {
+			verboseDebug("duplicate model skipped: %s", model.Name)
+			continue
+		}
+		models = append(models, ollamaModel{name: name, listedName: model.Name, details: modelDetails{
+			digest:         model.Digest,
+			modifiedAt:     model.ModifiedAt,
+			parameterCount: parseParameterSize(model.Details.ParameterSize),
+			size:           model.Size,
+			family:         model.Details.Family,
+			parameterSize:  model.Details.ParameterSize,
+			quantization:   model.Details.QuantizationLevel,
+		}})
+	}
+	return models, nil
+}
+
+// parseParameterSize parses the parameter size of ollama, e.g. 8.0B or 137M, 0 if unknown.
+func parseParameterSize(size string) float64 {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0
+	}
+	units := map[byte]float64{'K': 1e3, 'M': 1e6, 'B': 1e9, 'T': 1e12}
+	unit, ok := units[size[len(size)-1]]
+	if ok {
+		size = size[:len(size)-1]
+	} else {
+		unit = 1
+	}
+	n, err := strconv.ParseFloat(size, 64)
+	if err != nil {
+		return 0
+	}
+	return n * unit
+}
+
+// normalizeLatest strips or adds the :latest tag of the model name according to --normalize-latest.
+func normalizeLatest(name string) (string, error) {
+	switch optNormLatest {
+	case "":
+		return name, nil
+	case "strip":
+		return strings.TrimSuffix(name, ":latest"), nil
+	case "add":
+		if !strings.Contains(name, ":") {
+			return name + ":latest", nil
+		}
+		return name, nil
+	default:
+		return "", tracerr.Errorf("unknown normalize-latest mode: %s", optNormLatest)
+	}
+}
+
+// getOllamaModels returns the models on the disk of ollama by /api/tags.
+func getOllamaModels(c *olmapi.Client) ([]ollamaModel, error) {
+	resp, err := c.List(context.Background())
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return getListedModels(resp)
+}
+
+// getRunningOllamaModels returns the models loaded in memory of ollama by /api/ps, the same as
+// getOllamaModels but the time of modification is not known of a running model.
+func getRunningOllamaModels(c *olmapi.Client) ([]ollamaModel, error) {
+	resp, err := c.ListRunning(context.Background())
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	list := &olmapi.ListResponse{Models: []olmapi.ListModelResponse{}}
+	for _, model := range resp.Models {
+		list.Models = append(list.Models, olmapi.ListModelResponse{
+			Name:    model.Name,
+			Model:   model.Model,
+			Size:    model.Size,
+			Digest:  model.Digest,
+			Details: model.Details,
+		})
+	}
+	return getListedModels(list)
+}
+
+func getModelInfo(c *olmapi.Client, model string) (*olmapi.ShowResponse, error) {
+	resp, err := c.Show(context.Background(), &olmapi.ShowRequest{Model: model})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return resp, nil
+}
+
+func createOllamaClient(apiBase, apiKey string, tlsOpts tlsOptions, proxyURL *url.URL) (*olmapi.Client, error) {
+	httpClient, u, err := createHTTPClient(apiBase, apiKey, tlsOpts, proxyURL)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if u == nil {
+		// same as olmapi.ClientFromEnvironment, but with the http client above
+		u = envconfig.Host()
+	} else {
+		// remove the path
+		u.Path = ""
+	}
+	return olmapi.NewClient(u, httpClient), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestModelGoneSinceListed(t *testing.T) {
+	gone := mockModel{name: "gone:1b", family: "llama", contextLen: 1024, showStatus: http.StatusNotFound}
+	mock := newOllamaMock(t, append(testModels, gone)...)
+	cfgFile := writeFile(t, "config.yaml", ollamaConfig(mock.URL))
+
+	res := runMain(t, "", "-c", cfgFile)
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "model not found on the server, skipped: gone:1b") {
+		t.Errorf("skip not warned:\n%s", res.stderr)
+	}
+	names := clientModelNames(t, decodeConfig(t, res.stdout), "ollama")
+	if slices.Contains(names, "gone:1b") || len(names) != len(testModels) {
+		t.Errorf("models of the output: %v", names)
+	}
+
+	// another error of the server fails the sync
+	broken := mockModel{name: "broken:1b", family: "llama", contextLen: 1024, showStatus: http.StatusInternalServerError}
+	mock = newOllamaMock(t, append(testModels, broken)...)
+	cfgFile = writeFile(t, "config.yaml", ollamaConfig(mock.URL))
+	res = runMain(t, "", "-c", cfgFile)
+	if res.code != exitConnError || res.stdout != "" {
+		t.Errorf("exit code %d, stdout %q: %s", res.code, res.stdout, res.stderr)
+	}
+}
+
+func TestDumpModelsOfClient(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	// the connection is of the client in the config
+	cfgFile := writeFile(t, "config.yaml", ollamaConfig(mock.URL))
+	res := runMain(t, "", "dump-models", "-c", cfgFile)
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	var dump modelsDump
+	if err := json.Unmarshal([]byte(res.stdout), &dump); err != nil {
+		t.Fatalf("%v: %s", err, res.stdout)
+	}
+	if len(dump.Tags.Models) != len(testModels) || len(dump.Show) != len(testModels) {
+		t.Errorf("models dumped: %d, shown: %d", len(dump.Tags.Models), len(dump.Show))
+	}
+
+	// without a config, by the options only
+	res = runMain(t, "", "dump-models", "--api-base", mock.URL)
+	if res.code != exitOK || !strings.Contains(res.stdout, testModels[0].name) {
+		t.Errorf("exit code %d: %s", res.code, res.stderr)
+	}
+}
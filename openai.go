@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                          OPENAI COMPATIBLE SOURCE                          */
+/* -------------------------------------------------------------------------- */
+
+// openaiSource is the model source of OpenAI compatible servers, e.g. vLLM or LiteLLM.
+type openaiSource struct {
+	httpClient *http.Client
+	baseURL    *url.URL                    // api_base, e.g. http://localhost:8000/v1
+	models     map[string]*modelParameters // parameters found in the models response
+}
+
+func (s *openaiSource) name() string {
+	return "openai-compatible"
+}
+
+// listModels returns the models of GET {api_base}/models.
+func (s *openaiSource) listModels() ([]ollamaModel, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, s.baseURL.JoinPath("models").String(), nil)
+	if err != nil {
+		return []ollamaModel{}, tracerr.Wrap(err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return []ollamaModel{}, tracerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []ollamaModel{}, tracerr.Errorf("list models: %s", resp.Status)
+	}
+	var body struct {
+		Data []struct {
+			ID string `json:"id"`
+			// context length, exposed by some servers only
+			MaxModelLen   int `json:"max_model_len"`  // vLLM
+			ContextLength int `json:"context_length"` // OpenRouter
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return []ollamaModel{}, tracerr.Errorf("list models: %w", err)
+	}
+	models := []ollamaModel{}
+	s.models = map[string]*modelParameters{}
+	for _, data := range body.Data {
+		if data.ID == "" || lo.ContainsBy(models, func(m ollamaModel) bool { return m.name == data.ID }) {
+			continue
+		}
+		params := newModelParameters()
+		if ctxLen := max(data.MaxModelLen, data.ContextLength); ctxLen > 0 {
+			params.maxContextLength = ctxLen
+		}
+		s.models[data.ID] = params
+		models = append(models, ollamaModel{name: data.ID, listedName: data.ID})
+	}
+	return models, nil
+}
+
+// showModel returns the parameters found in the models response.
+func (s *openaiSource) showModel(model string) (*modelParameters, error) {
+	if params, ok := s.models[model]; ok {
+		return params, nil
+	}
+	return newModelParameters(), tracerr.Errorf("%w: %s", errModelNotFound, model)
+}
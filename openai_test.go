@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// newOpenAIMock starts an OpenAI compatible server listing the models for the api key, closed at the end of
+// the test.
+func newOpenAIMock(t *testing.T, apiKey string, models ...map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/models" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+apiKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAICompatibleSource(t *testing.T) {
+	server := newOpenAIMock(t, "token",
+		map[string]any{"id": "meta-llama/Llama-3.1-8B-Instruct", "object": "model", "max_model_len": 16384},
+		map[string]any{"id": "Qwen/Qwen2.5-7B-Instruct", "object": "model"},
+	)
+	config := `model: vllm:meta-llama/Llama-3.1-8B-Instruct
+clients:
+  - type: openai-compatible
+    name: vllm
+    api_base: ` + server.URL + `/v1
+    api_key: token
+    models:
+      - name: Qwen/Qwen2.5-7B-Instruct
+        max_input_tokens: 32768
+      - name: removed/model
+`
+	cfgFile := writeFile(t, "config.yaml", config)
+
+	res := runMain(t, "", "-c", cfgFile)
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	models := map[string]map[string]any{}
+	for _, model := range decodeConfig(t, res.stdout)["clients"].([]any)[0].(map[string]any)["models"].([]any) {
+		model := model.(map[string]any)
+		models[model["name"].(string)] = model
+	}
+	if len(models) != 2 {
+		t.Errorf("models of the output: %v", models)
+	}
+	// the context length is of the list, the other fields are omitted when not exposed
+	if added := models["meta-llama/Llama-3.1-8B-Instruct"]; added["max_input_tokens"] != 16384 || len(added) != 2 {
+		t.Errorf("added model: %v", added)
+	}
+	if kept := models["Qwen/Qwen2.5-7B-Instruct"]; kept["max_input_tokens"] != 32768 {
+		t.Errorf("existing model: %v", kept)
+	}
+
+	// the api key is rejected
+	cfgFile = writeFile(t, "config.yaml", strings.Replace(config, "api_key: token", "api_key: wrong", 1))
+	res = runMain(t, "", "-c", cfgFile)
+	if res.code != exitConnError {
+		t.Errorf("exit code %d, expected %d: %s", res.code, exitConnError, res.stderr)
+	}
+}
+
+// TestShowModelNotFound checks a model not listed is skipped by the sync as a model gone from the server.
+func TestShowModelNotFound(t *testing.T) {
+	sources := []modelSource{&openaiSource{}, &lmstudioSource{}, &llamaServerSource{}}
+	for _, source := range sources {
+		if _, err := source.showModel("gone"); !errors.Is(err, errModelNotFound) {
+			t.Errorf("%s: %v", source.name(), err)
+		}
+	}
+}
+
+// TestSourceOfClient checks the source is selected by extra.source of the client, not by its name.
+func TestSourceOfClient(t *testing.T) {
+	tests := []struct{ client, source string }{
+		{"type: openai-compatible\nname: llama-server\n", "openai-compatible"},
+		{"type: openai-compatible\nname: local\nextra:\n  source: llama-server\n", "llama-server"},
+		{"type: openai-compatible\nname: lmstudio\n", "lmstudio"},
+		{"type: ollama\n", "ollama"},
+	}
+	for _, tt := range tests {
+		var client yaml.Node
+		if err := yaml.Unmarshal([]byte(tt.client), &client); err != nil {
+			t.Fatal(err)
+		}
+		if source := getSourceName(client.Content[0]); source != tt.source {
+			t.Errorf("%q: source %s, expected %s", tt.client, source, tt.source)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/zrs01/aichatconf/internal/aichat"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// overrideRule sets the fields of the models whose name matches the glob pattern and whose family is the family
+// listed by the server, an empty match or family matches any model.
+type overrideRule struct {
+	match  string
+	family string
+	set    *yaml.Node // mapping node of the fields
+}
+
+// loadOverrides reads the overrides file, a list of rules like
+//
+//	# overrides.yaml
+//	- match: "llava*"
+//	  set:
+//	    supports_vision: true
+//	- family: qwen2
+//	  set:
+//	    patch: {chat_completions: {".*": {options: {num_gpu: 99}}}}
+//
+// or a map of the patterns to the fields in order, like
+//
+//	"deepseek-r1*":
+//	  no_system_message: true
+//
+// the fields are checked against aichat.ClientModel.
+func loadOverrides(filename string) ([]overrideRule, error) {
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, tracerr.Errorf("invalid overrides file (%s): %w", filename, err)
+	}
+	if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+		return loadOverridesMap(filename, doc.Content[0])
+	}
+	var entries []struct {
+		Match  string             `yaml:"match"`
+		Family string             `yaml:"family"`
+		Set    aichat.ClientModel `yaml:"set"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&entries); err != nil && err != io.EOF {
+		return nil, tracerr.Errorf("invalid overrides file (%s): %w", filename, err)
+	}
+	rules := []overrideRule{}
+	for i, entry := range entries {
+		if entry.Match == "" && entry.Family == "" {
+			return nil, tracerr.Errorf("invalid overrides file (%s): match and family of rule %d are empty", filename, i+1)
+		}
+		if _, err := matchModelName("glob", entry.Match, ""); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		var node yaml.Node
+		if err := node.Encode(entry.Set); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		rules = append(rules, overrideRule{match: entry.Match, family: entry.Family, set: &node})
+	}
+	verboseInfo("overrides read: %s, %d rules", filename, len(rules))
+	return rules, nil
+}
+
+// loadOverridesMap reads the rules of the overrides file in the map format, the order of the patterns is kept.
+func loadOverridesMap(filename string, root *yaml.Node) ([]overrideRule, error) {
+	rules := []overrideRule{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		pattern := root.Content[i].Value
+		if _, err := matchModelName("glob", pattern, ""); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		// check the fields by decoding them strictly
+		body, err := yaml.Marshal(root.Content[i+1])
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		var set aichat.ClientModel
+		decoder := yaml.NewDecoder(bytes.NewReader(body))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&set); err != nil && err != io.EOF {
+			return nil, tracerr.Errorf("invalid overrides file (%s): %s: %w", filename, pattern, err)
+		}
+		var node yaml.Node
+		if err := node.Encode(set); err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		rules = append(rules, overrideRule{match: pattern, set: &node})
+	}
+	verboseInfo("overrides read: %s, %d rules", filename, len(rules))
+	return rules, nil
+}
+
+// applyOverrides sets the fields, except name, of the matching rules on the model node in order,
+// so the last matching rule wins. It returns the keys set.
+func applyOverrides(node *yaml.Node, name string, rules []overrideRule) []string {
+	keys := []string{}
+	for _, rule := range rules {
+		if matched, _ := matchModelName("glob", rule.match, name); rule.match != "" && !matched {
+			continue
+		}
+		if rule.family != "" && rule.family != modelFamily(name) {
+			continue
+		}
+		for i := 0; i+1 < len(rule.set.Content); i += 2 {
+			key := rule.set.Content[i].Value
+			if key == "name" {
+				continue
+			}
+			setNodeField(node, key, copyNode(rule.set.Content[i+1]))
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// modelFamily returns the family of the model listed by the server, empty if unknown.
+func modelFamily(model string) string {
+	src, ok := modelSrc.(detailedSource)
+	if !ok {
+		return ""
+	}
+	details, _ := src.modelDetails(realModelName(model))
+	return details.family
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestOverridesForceCapability(t *testing.T) {
+	mock := newOllamaMock(t, append(testModels, mockModel{name: "llava-custom:7b", family: "llama", contextLen: 4096,
+		capabilities: []string{"completion"}})...)
+	cfgFile := writeFile(t, "config.yaml", ollamaConfig(mock.URL,
+		"models:",
+		"  - name: qwen2.5:14b",
+		"    max_input_tokens: 32768",
+	))
+	overrides := writeFile(t, "overrides.yaml", `- match: "llava*"
+  set:
+    supports_vision: true
+- match: "qwen*"
+  set:
+    max_input_tokens: 16384
+- match: "qwen2.5:*"
+  set:
+    max_input_tokens: 8192
+`)
+
+	res := runMain(t, "", "-c", cfgFile, "--overrides", overrides)
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	models := map[string]map[string]any{}
+	for _, model := range decodeConfig(t, res.stdout)["clients"].([]any)[0].(map[string]any)["models"].([]any) {
+		model := model.(map[string]any)
+		models[model["name"].(string)] = model
+	}
+	// the capability missed by the detection is forced on the new model
+	if models["llava-custom:7b"]["supports_vision"] != true {
+		t.Errorf("supports_vision not forced: %v", models["llava-custom:7b"])
+	}
+	// the last matching rule wins on the existing model
+	if models["qwen2.5:14b"]["max_input_tokens"] != 8192 {
+		t.Errorf("max_input_tokens not overridden: %v", models["qwen2.5:14b"])
+	}
+	if _, ok := models["llama3:latest"]["supports_vision"]; ok {
+		t.Errorf("override applied to the model not matching: %v", models["llama3:latest"])
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// parsePatch parses the patch of --patch, a JSON or YAML mapping, nil if empty.
+func parsePatch(value string) (*yaml.Node, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, tracerr.Errorf("invalid patch: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, tracerr.Errorf("invalid patch, a mapping is expected: %s", value)
+	}
+	patch := doc.Content[0]
+	// write the patch in the style of the rest of the config, a JSON patch is parsed in flow style and quoted,
+	// the scalars are still quoted by the encoder when needed and the model patterns are quoted as aichat does
+	var setStyle func(node *yaml.Node)
+	setStyle = func(node *yaml.Node) {
+		switch {
+		case node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode:
+			node.Style &^= yaml.FlowStyle
+		case node.Kind == yaml.ScalarNode && node.Style == yaml.DoubleQuotedStyle && !strings.ContainsAny(node.Value, ".*"):
+			node.Style = 0
+		}
+		for _, child := range node.Content {
+			setStyle(child)
+		}
+	}
+	setStyle(patch)
+	return patch, nil
+}
+
+// patchNumCtx returns the num_ctx of --patch-num-ctx for the model of the context length, capped by the
+// context length, or the context length itself for max. Zero if not to be patched.
+func patchNumCtx(contextLength int) (int, error) {
+	if optPatchNumCtx == "" {
+		return 0, nil
+	}
+	if optPatchNumCtx == "max" {
+		return max(contextLength, 0), nil
+	}
+	numCtx, err := strconv.Atoi(optPatchNumCtx)
+	if err != nil || numCtx <= 0 {
+		return 0, tracerr.Errorf("invalid patch-num-ctx, a positive number or max: %s", optPatchNumCtx)
+	}
+	if contextLength > 0 && contextLength < numCtx {
+		return contextLength, nil
+	}
+	return numCtx, nil
+}
+
+// applyPatchNumCtx patches num_ctx of the chat model by --patch-num-ctx and returns the num_ctx, zero if not patched.
+func applyPatchNumCtx(node *yaml.Node, model string) (int, error) {
+	if optPatchNumCtx == "" {
+		return 0, nil
+	}
+	if modelType, ok := getNodeValue(node, "type", yaml.ScalarNode); ok && modelType.Value != "chat" {
+		return 0, nil
+	}
+	params, err := getModelParameters(model)
+	if errors.Is(err, errModelNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	numCtx, err := patchNumCtx(params.maxContextLength)
+	if err != nil || numCtx == 0 {
+		return 0, tracerr.Wrap(err)
+	}
+	setPatchNumCtx(node, numCtx)
+	return numCtx, nil
+}
+
+// setAllParameters sets all parameters of the model by --all-params, num_ctx as max_input_tokens and the ones
+// not detected otherwise as the options in the patch. It returns the keys set.
+func setAllParameters(node *yaml.Node, params *modelParameters) []string {
+	keys := []string{}
+	names := lo.Keys(params.parameters)
+	sort.Strings(names)
+	for _, key := range names {
+		values := params.parameters[key]
+		switch key {
+		case "temperature", "top_p", "num_predict":
+			// detected already
+		case "num_ctx":
+			setNodeField(node, "max_input_tokens", &yaml.Node{Kind: yaml.ScalarNode, Value: values[len(values)-1]})
+			keys = append(keys, "max_input_tokens")
+		default:
+			value := &yaml.Node{Kind: yaml.ScalarNode, Value: values[0]}
+			if len(values) > 1 || key == "stop" {
+				value = &yaml.Node{Kind: yaml.SequenceNode}
+				for _, v := range values {
+					value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+				}
+			}
+			setNodeField(patchOptions(node), key, value)
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// patchOptions returns the ollama options in the patch of the model node, added if missing.
+func patchOptions(node *yaml.Node) *yaml.Node {
+	options := node
+	for _, key := range []string{"patch", "chat_completions", ".*", "options"} {
+		options = getOrAddMapping(options, key)
+	}
+	return options
+}
+
+// setPatchNumCtx sets num_ctx in the patch of the model node, merged into the existing patch.
+func setPatchNumCtx(node *yaml.Node, numCtx int) {
+	setNodeField(patchOptions(node), "num_ctx", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(numCtx)})
+}
+
+// getOrAddMapping returns the mapping value of the key in the mapping node, added if missing.
+func getOrAddMapping(node *yaml.Node, key string) *yaml.Node {
+	if value, ok := getNodeValue(node, key, yaml.MappingNode); ok {
+		return value
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	if strings.ContainsAny(key, ".*") {
+		// quote the model patterns as aichat does
+		keyNode.Style = yaml.DoubleQuotedStyle
+	}
+	node.Content = append(node.Content, keyNode, value)
+	return value
+}
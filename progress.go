@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// progressLogInterval is the interval of the log lines of the progress when stderr is not a terminal.
+const progressLogInterval = 5 * time.Second
+
+// fetchProgress is the progress of fetching the model info during the sync, nil if not shown.
+var fetchProgress *progress
+
+// progress prints a counter like "fetching model info 12/40: llama3" on a single line of stderr, overwritten
+// on each step and cleared before a log entry and when done, so nothing is left in the scrollback. When stderr
+// is not a terminal the counter is logged every progressLogInterval instead.
+type progress struct {
+	label   string
+	total   int
+	current int
+	shown   bool
+	tty     bool
+	logged  time.Time // time of the last log line, or of the start
+}
+
+// startProgress returns the progress of the total steps, nil under --quiet or when there is nothing to do.
+func startProgress(label string, total int) *progress {
+	if !logrus.IsLevelEnabled(logrus.InfoLevel) || total == 0 {
+		return nil
+	}
+	return &progress{label: label, total: total, tty: isCharDevice(os.Stderr), logged: time.Now()}
+}
+
+// step counts a completed step of the item and prints the progress.
+func (p *progress) step(item string) {
+	if p == nil {
+		return
+	}
+	p.current = min(p.current+1, p.total)
+	if !p.tty {
+		if time.Since(p.logged) >= progressLogInterval {
+			p.logged = time.Now()
+			logrus.Infof("%s %d/%d: %s", p.label, p.current, p.total, item)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s %d/%d: %s", p.label, p.current, p.total, item)
+	p.shown = true
+}
+
+// clear clears the printed progress.
+func (p *progress) clear() {
+	if p == nil || !p.shown {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	p.shown = false
+}
+
+// progressHook clears the progress before a log entry is written, the next step prints it again.
+type progressHook struct{}
+
+func (progressHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (progressHook) Fire(*logrus.Entry) error {
+	fetchProgress.clear()
+	return nil
+}
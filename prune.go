@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// prunedHeader starts a model commented out by --prune-mode comment, e.g. "# pruned 2024-06-01:".
+var prunedHeader = regexp.MustCompile(`^# pruned \d{4}-\d{2}-\d{2}:$`)
+
+// prunedIndent prefixes the lines of the YAML of a model commented out.
+const prunedIndent = "#   "
+
+// prunedModels are the models commented out in the head comment of the models key of the client by
+// --prune-mode comment, restored when they are on the server again.
+type prunedModels struct {
+	key    *yaml.Node // models key of the client, nil if not found
+	lines  []string   // lines of the head comment other than the pruned models
+	blocks []prunedModel
+}
+
+type prunedModel struct {
+	name  string
+	lines []string // header and the YAML of the model as commented
+}
+
+// checkPruneMode checks the mode of --prune-mode.
+func checkPruneMode() error {
+	if optPruneMode != "delete" && optPruneMode != "comment" {
+		return tracerr.Errorf("unknown prune mode: %s", optPruneMode)
+	}
+	return nil
+}
+
+// loadPrunedModels reads the models commented out in the head comment of the models key of the client,
+// the lines which cannot be read as a model are kept as they are.
+func loadPrunedModels(client *yaml.Node) *prunedModels {
+	pruned := &prunedModels{}
+	for i := 0; i+1 < len(client.Content); i += 2 {
+		if client.Content[i].Value == "models" {
+			pruned.key = client.Content[i]
+		}
+	}
+	if pruned.key == nil || pruned.key.HeadComment == "" {
+		return pruned
+	}
+	lines := strings.Split(pruned.key.HeadComment, "\n")
+	for i := 0; i < len(lines); i++ {
+		if !prunedHeader.MatchString(lines[i]) {
+			pruned.lines = append(pruned.lines, lines[i])
+			continue
+		}
+		block := prunedModel{lines: []string{lines[i]}}
+		body := []string{}
+		for i+1 < len(lines) && strings.HasPrefix(lines[i+1], prunedIndent) {
+			i++
+			block.lines = append(block.lines, lines[i])
+			body = append(body, strings.TrimPrefix(lines[i], prunedIndent))
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.Join(body, "\n")), &node); err == nil && len(node.Content) > 0 {
+			if name, ok := getNodeValue(node.Content[0], "name", yaml.ScalarNode); ok {
+				block.name = name.Value
+				pruned.blocks = append(pruned.blocks, block)
+				continue
+			}
+		}
+		pruned.lines = append(pruned.lines, block.lines...)
+	}
+	return pruned
+}
+
+// add comments out the removed model.
+func (p *prunedModels) add(node *yaml.Node) error {
+	name, _ := getNodeValue(node, "name", yaml.ScalarNode)
+	body, err := marshalYAML(node)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	block := prunedModel{name: name.Value, lines: []string{"# pruned " + time.Now().Format("2006-01-02") + ":"}}
+	for _, line := range strings.Split(strings.TrimRight(string(body), "\n"), "\n") {
+		block.lines = append(block.lines, prunedIndent+line)
+	}
+	// the latest of the same model wins
+	p.remove(name.Value)
+	p.blocks = append(p.blocks, block)
+	return nil
+}
+
+// restore returns the model commented out of the name and removes it from the comment, nil if not found.
+func (p *prunedModels) restore(name string) *yaml.Node {
+	for _, block := range p.blocks {
+		if block.name != name {
+			continue
+		}
+		body := lo.Map(block.lines[1:], func(line string, _ int) string { return strings.TrimPrefix(line, prunedIndent) })
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.Join(body, "\n")), &node); err != nil {
+			return nil
+		}
+		p.remove(name)
+		// the head comment of the model is read as of its first key, it is moved back to the model
+		model := node.Content[0]
+		if model.HeadComment == "" && len(model.Content) > 0 {
+			model.HeadComment, model.Content[0].HeadComment = model.Content[0].HeadComment, ""
+		}
+		return model
+	}
+	return nil
+}
+
+func (p *prunedModels) remove(name string) {
+	p.blocks = lo.Filter(p.blocks, func(block prunedModel, _ int) bool { return block.name != name })
+}
+
+// write writes the models commented out to the head comment of the models key, after the other lines and
+// before the stamp of --stamp, which stays next to the key.
+func (p *prunedModels) write() {
+	if p.key == nil {
+		return
+	}
+	lines, stamps := lo.FilterReject(p.lines, func(line string, _ int) bool { return !strings.HasPrefix(line, stampPrefix) })
+	for _, block := range p.blocks {
+		lines = append(lines, block.lines...)
+	}
+	p.key.HeadComment = strings.Join(append(lines, stamps...), "\n")
+}
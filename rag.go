@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// fixRagModel checks the rag model setting, e.g. rag_embedding_model, refers to a model of the client after the sync.
+// The requested model is set if given; a missing or stale setting is pointed at the first model of the type with
+// auto, and a stale one is cleared otherwise. The comment of the setting is kept.
+func fixRagModel(root, models *yaml.Node, key, modelType, requested string, auto bool) {
+	node, found := getNodeValue(root, key, yaml.ScalarNode)
+	if requested != "" {
+		name := strings.TrimPrefix(requested, optClientName+":")
+		if findModelNode(models, name) == nil {
+			logrus.Warnf("%s not set, model not found: %s", key, requested)
+			return
+		}
+		setRagModel(root, node, key, fmt.Sprintf("%s:%s", optClientName, name))
+		return
+	}
+
+	stale := false
+	if found && node.Tag != "!!null" && node.Value != "" {
+		client, name, _ := strings.Cut(node.Value, ":")
+		// the models of other clients are not known
+		if client != optClientName || findModelNode(models, name) != nil {
+			return
+		}
+		stale = true
+	}
+	if !stale && !auto {
+		return
+	}
+	if auto {
+		for _, cfgModel := range models.Content {
+			typeNode, ok := getNodeValue(cfgModel, "type", yaml.ScalarNode)
+			nameNode, hasName := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+			if ok && hasName && typeNode.Value == modelType {
+				if stale {
+					logrus.Warnf("%s refers to a removed model: %s", key, node.Value)
+				}
+				setRagModel(root, node, key, fmt.Sprintf("%s:%s", optClientName, nameNode.Value))
+				return
+			}
+		}
+		if !stale {
+			verboseInfo("%s not set, no %s model found", key, modelType)
+			return
+		}
+	}
+	logrus.Warnf("%s cleared, it refers to a removed model: %s", key, node.Value)
+	node.Tag = "!!null"
+	node.Value = "null"
+	node.Style = 0
+}
+
+// setRagModel sets the rag model setting, the node is nil if the setting is missing.
+func setRagModel(root, node *yaml.Node, key, value string) {
+	if node == nil {
+		setNodeKeyValue(root, yaml.ScalarNode, key, yaml.ScalarNode, value)
+	} else {
+		node.Tag = "!!str"
+		node.Value = value
+		node.Style = 0
+	}
+	verboseInfo("set %s: %s", key, value)
+}
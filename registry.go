@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	olmmodel "github.com/ollama/ollama/types/model"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/zrs01/aichatconf/internal/aichat"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultSyncModelsURL is the models registry of aichat used when sync_models_url is not set.
+const defaultSyncModelsURL = "https://raw.githubusercontent.com/sigoden/aichat/refs/heads/main/models.yaml"
+
+// registryProvider is a provider of the models registry, the format of models.yaml of aichat.
+type registryProvider struct {
+	Provider string               `yaml:"provider"`
+	Models   []aichat.ClientModel `yaml:"models"`
+}
+
+// modelRegistry is the models of the registry of --use-sync-url by the name, nil if not used.
+var modelRegistry map[string]aichat.ClientModel
+
+// loadModelRegistry fetches the models registry of aichat, a YAML or JSON list of the providers and their
+// models, from the URL or a local file. A failure is only warned, the models are detected by the server only.
+func loadModelRegistry(location string) {
+	body, err := readRegistry(location)
+	if err == nil {
+		var providers []registryProvider
+		if err = yaml.Unmarshal(body, &providers); err == nil {
+			modelRegistry = map[string]aichat.ClientModel{}
+			for _, provider := range providers {
+				for _, model := range provider.Models {
+					// the models of ollama win over the same name of the other providers
+					if _, ok := modelRegistry[model.Name]; !ok || provider.Provider == "ollama" {
+						modelRegistry[model.Name] = model
+					}
+				}
+			}
+			verboseInfo("models registry read: %s, %d models", location, len(modelRegistry))
+			return
+		}
+	}
+	logrus.Warnf("models registry not available, ignored: %s: %v", location, err)
+}
+
+func readRegistry(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		body, err := os.ReadFile(location)
+		return body, tracerr.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, tracerr.Errorf("fetch models registry: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return body, tracerr.Wrap(err)
+}
+
+// marshalModelsYAML marshals the models of the client of --client in the format of the models registry, the
+// provider is the name of the client, or the type if the client has no name. The settings of aichatconf, e.g. keep, are dropped.
+func marshalModelsYAML(root *yaml.Node) ([]byte, error) {
+	provider := registryProvider{Provider: optClientName, Models: []aichat.ClientModel{}}
+	var cfg aichat.ConfigStruct
+	if err := root.Decode(&cfg); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if client, ok := lo.Find(cfg.Clients, func(c aichat.Client) bool { return aichatClientName(c) == optClientName }); ok {
+		provider.Models = lo.Map(client.Models, func(m aichat.ClientModel, _ int) aichat.ClientModel {
+			m.Keep = nil
+			return m
+		})
+	}
+	return marshalYAML([]registryProvider{provider})
+}
+
+// fillFromRegistry fills the parameters unknown by the server from the registry, the model is looked up by the
+// name and then by the name without the tag.
+func fillFromRegistry(model string, params *modelParameters) {
+	if modelRegistry == nil {
+		return
+	}
+	entry, ok := modelRegistry[model]
+	if !ok {
+		name, _, _ := strings.Cut(model, ":")
+		if entry, ok = modelRegistry[name]; !ok {
+			return
+		}
+	}
+	filled := []string{}
+	if params.maxContextLength < 0 && entry.MaxInputTokens != nil {
+		params.maxContextLength = *entry.MaxInputTokens
+		filled = append(filled, "max_input_tokens")
+	}
+	if params.maxOutputTokens < 0 && entry.MaxOutputTokens != nil {
+		params.maxOutputTokens = *entry.MaxOutputTokens
+		filled = append(filled, "max_output_tokens")
+	}
+	for _, capability := range []struct {
+		supported  *bool
+		capability olmmodel.Capability
+	}{
+		{entry.SupportsVision, olmmodel.CapabilityVision},
+		{entry.SupportsFunctionCalling, olmmodel.CapabilityTools},
+		{entry.SupportsReasoning, olmmodel.CapabilityThinking},
+	} {
+		if capability.supported != nil && *capability.supported && !lo.Contains(params.capabilities, capability.capability) {
+			params.capabilities = append(params.capabilities, capability.capability)
+			filled = append(filled, string(capability.capability))
+		}
+	}
+	if len(filled) > 0 {
+		verboseDebug("filled from models registry: %s (%s)", model, strings.Join(filled, ", "))
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestModelsYAMLOfClientWithoutName(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := strings.Replace(ollamaConfig(mock.URL), "    name: ollama\n", "", 1)
+
+	res := runMain(t, "", "-c", writeFile(t, "config.yaml", config), "--format", "models-yaml")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	var providers []registryProvider
+	if err := yaml.Unmarshal([]byte(res.stdout), &providers); err != nil {
+		t.Fatalf("output is not YAML: %v\n%s", err, res.stdout)
+	}
+	if len(providers) != 1 || providers[0].Provider != "ollama" || len(providers[0].Models) != len(testModels) {
+		t.Errorf("providers of the output:\n%s", res.stdout)
+	}
+}
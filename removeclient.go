@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// removeClient removes the client of --client from the clients of the config, the rest of the config is kept
+// as is. The settings referring to the client fail the removal unless --force, they are warned then.
+func removeClient() error {
+	if optClientName == "" {
+		return tracerr.New("client name is required, use --client")
+	}
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err := cfg.findClient(); err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	root := cfg.doc.Content[0]
+	refs := []string{}
+	for _, key := range []string{"model", "rag_embedding_model", "rag_reranker_model"} {
+		if node, ok := getNodeValue(root, key, yaml.ScalarNode); ok {
+			if clientName, _, _ := strings.Cut(node.Value, ":"); strings.TrimSpace(clientName) == optClientName {
+				refs = append(refs, fmt.Sprintf("%s: %s", key, node.Value))
+			}
+		}
+	}
+	if len(refs) > 0 && !optForce {
+		return tracerr.Errorf("client is referred, use --force to remove it: %s", strings.Join(refs, ", "))
+	}
+	for _, ref := range refs {
+		logrus.Warnf("removed client is referred: %s", ref)
+	}
+
+	cfg.clients.Content = lo.Filter(cfg.clients.Content, func(cn *yaml.Node, _ int) bool { return cn != cfg.client })
+	if len(cfg.clients.Content) == 0 {
+		// keep the key as "clients: []"
+		cfg.clients.Style = yaml.FlowStyle
+	}
+	verboseInfo("remove client: %s", optClientName)
+
+	return cfg.write(syncSummary{clients: 1}, optClientName)
+}
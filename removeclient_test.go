@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRemoveClientWritePath(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := ollamaConfig(mock.URL) + "  - type: openai-compatible\n    name: vllm\n    api_base: http://localhost:8000/v1\n"
+	cfgFile := writeFile(t, "config.yaml", config)
+	readConfig := func() string {
+		t.Helper()
+		body, err := os.ReadFile(cfgFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	remove := []string{"remove-client", "-c", cfgFile, "-o", cfgFile, "--client", "vllm"}
+	// --dry-run prints the config and keeps the file
+	res := runMain(t, "", append(remove, "--dry-run")...)
+	if res.code != exitOK || strings.Contains(res.stdout, "name: vllm") || readConfig() != config {
+		t.Fatalf("dry run, exit code %d: %s\n%s", res.code, res.stderr, res.stdout)
+	}
+	res = runMain(t, "", append(remove, "--yes", "-v")...)
+	if res.code != exitOK || res.stdout != "" || strings.Contains(readConfig(), "name: vllm") {
+		t.Fatalf("exit code %d: %s\n%s", res.code, res.stderr, res.stdout)
+	}
+	// the output file of the same content is not written again
+	res = runMain(t, "", "remove-client", "-c", writeFile(t, "config.yaml", readConfig()+"    - type: openai\n      name: gone\n"),
+		"-o", cfgFile, "--client", "gone", "-v")
+	if res.code != exitOK || !strings.Contains(res.stderr, "no changes, write skipped") {
+		t.Errorf("exit code %d: %s", res.code, res.stderr)
+	}
+
+	// the output is verified
+	invalid := writeFile(t, "config.yaml", config+"  - type: openai\n    name: other\n    models:\n      - name: m\n      - name: m\n")
+	res = runMain(t, "", "remove-client", "-c", invalid, "--client", "ollama", "--force")
+	if res.code != exitInvalid || res.stdout != "" {
+		t.Errorf("exit code %d, stdout %q: %s", res.code, res.stdout, res.stderr)
+	}
+	res = runMain(t, "", "remove-client", "-c", invalid, "--client", "ollama", "--force", "--no-verify")
+	if res.code != exitOK || !strings.Contains(res.stdout, "name: other") {
+		t.Errorf("--no-verify, exit code %d: %s", res.code, res.stderr)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zrs01/aichatconf/internal/aichat"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// configSetting is a top level key of the config set by --set key=value.
+type configSetting struct {
+	key   string
+	value string
+	tag   string // tag of the value by the type of the key in aichat.ConfigStruct
+}
+
+// parseSettings parses the key=value entries of --set, the value of a key of aichat.ConfigStruct must be of
+// its type, and an unknown key, e.g. of a newer aichat, is a string.
+func parseSettings(entries []string) ([]configSetting, error) {
+	settings := []configSetting{}
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, tracerr.Errorf("invalid --set, key=value is expected: %s", entry)
+		}
+		tag, err := settingTag(key, value)
+		if err != nil {
+			return nil, tracerr.Errorf("invalid --set %s: %w", key, err)
+		}
+		settings = append(settings, configSetting{key: key, value: value, tag: tag})
+	}
+	return settings, nil
+}
+
+// settingTag returns the tag of the value of the key by the type of the field in aichat.ConfigStruct, or
+// the tag of a string for an unknown key.
+func settingTag(key, value string) (string, error) {
+	t := reflect.TypeOf(aichat.ConfigStruct{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); name != key {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.String:
+			return "!!str", nil
+		case reflect.Bool:
+			if value != "true" && value != "false" {
+				return "", tracerr.Errorf("true or false is expected: %s", value)
+			}
+			return "!!bool", nil
+		case reflect.Int:
+			if _, err := strconv.Atoi(value); err != nil {
+				return "", tracerr.Errorf("an integer is expected: %s", value)
+			}
+			return "!!int", nil
+		case reflect.Float64:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return "", tracerr.Errorf("a number is expected: %s", value)
+			}
+			return "!!float", nil
+		default:
+			return "", tracerr.Errorf("not a scalar key, edit %s in the config", key)
+		}
+	}
+	logrus.Warnf("unknown key of the aichat config, set as a string: %s", key)
+	return "!!str", nil
+}
+
+// isGlobalValue reports whether the number is the value of the key at the top level of the config, as set by
+// --set or else as in the config, e.g. the temperature of a model equal to the global one.
+func isGlobalValue(root *yaml.Node, settings []configSetting, key string, value float64) bool {
+	global := ""
+	if node, ok := getNodeValue(root, key, yaml.ScalarNode); ok {
+		global = node.Value
+	}
+	for _, setting := range settings {
+		if setting.key == key {
+			global = setting.value
+		}
+	}
+	f, err := strconv.ParseFloat(global, 64)
+	return err == nil && f == value
+}
+
+// applySettings sets the keys at the top level of the config in order, a missing key is appended, and the
+// comments of a present key are kept. It returns the number of the values changed.
+func applySettings(root *yaml.Node, settings []configSetting) int {
+	changed := 0
+	for _, setting := range settings {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: setting.tag, Value: setting.value}
+		if current, ok := getNodeValue(root, setting.key, yaml.ScalarNode); ok {
+			if current.Value == setting.value && current.ShortTag() == setting.tag {
+				verboseDebug("set %s, unchanged: %s", setting.key, setting.value)
+				continue
+			}
+			if setting.tag == "!!str" && current.ShortTag() == "!!str" {
+				// keep the quotes of the string as written
+				value.Style = current.Style
+			}
+		}
+		setNodeField(root, setting.key, value)
+		changed++
+		verboseInfo("set %s: %s", setting.key, setting.value)
+	}
+	return changed
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetKeys(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	cfgFile := writeFile(t, "config.yaml", "stream: true # of the terminal\n"+ollamaConfig(mock.URL))
+
+	res := runMain(t, "", "-c", cfgFile, "--set", "stream=false", "--set", "rag_top_k=8", "--set", "theme=dark")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	for _, line := range []string{"stream: false # of the terminal\n", "rag_top_k: 8\n", "theme: dark\n"} {
+		if !strings.Contains(res.stdout, line) {
+			t.Errorf("%q not in the output:\n%s", line, res.stdout)
+		}
+	}
+	if !strings.Contains(res.stderr, "unknown key of the aichat config, set as a string: theme") {
+		t.Errorf("unknown key not warned:\n%s", res.stderr)
+	}
+
+	for _, entry := range []string{"stream=yes", "rag_top_k=many", "clients=x", "=x"} {
+		if res := runMain(t, "", "-c", cfgFile, "--set", entry); res.code == exitOK {
+			t.Errorf("--set %s accepted:\n%s", entry, res.stdout)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// sortModes are the orders of the models of --sort.
+var sortModes = []string{"name", "size"}
+
+// checkSortMode checks the order of --sort, size needs the sizes listed by the source.
+func checkSortMode() error {
+	if !lo.Contains(sortModes, optSort) {
+		return tracerr.Errorf("unknown sort order (%s), one of %s", optSort, strings.Join(sortModes, ", "))
+	}
+	return nil
+}
+
+// sortModels sorts the models by the name, or by the file size listed by the server, the largest first, with
+// --sort size. The models of unknown size, e.g. kept ones not on the server, are last, ties break by the name.
+func sortModels(models *yaml.Node) error {
+	sizes := map[*yaml.Node]int64{}
+	if optSort == "size" {
+		src, ok := modelSrc.(detailedSource)
+		if !ok {
+			return tracerr.Errorf("sort by size is not supported by source: %s", modelSrc.name())
+		}
+		for _, cfgModel := range models.Content {
+			if name, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode); ok {
+				if details, ok := src.modelDetails(realModelName(name.Value)); ok {
+					sizes[cfgModel] = details.size
+				}
+			}
+		}
+	}
+	sort.Slice(models.Content, func(a, b int) bool {
+		if aSize, bSize := sizes[models.Content[a]], sizes[models.Content[b]]; aSize != bSize {
+			return aSize > bSize
+		}
+		aName, _ := getNodeValue(models.Content[a], "name", yaml.ScalarNode)
+		bName, _ := getNodeValue(models.Content[b], "name", yaml.ScalarNode)
+		return aName.Value < bName.Value
+	})
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCommentsOfModelsKept(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	config := `model: ollama:llama3:latest
+temperature: 0.5 # warm
+clients:
+    - type: ollama
+      name: ollama
+      api_base: ` + mock.URL + `/v1
+      models:
+        # for the RAG
+        - name: nomic-embed-text:latest
+          type: embedding
+        # the large one
+        - name: qwen2.5:14b # pulled by hand
+          max_input_tokens: 32768
+        # the default
+        - name: llama3:latest
+          max_input_tokens: 8192 # of the modelfile
+`
+	entries := map[string]string{
+		"llama3":  "        # the default\n        - name: llama3:latest\n          max_input_tokens: 8192 # of the modelfile\n",
+		"nomic":   "        # for the RAG\n        - name: nomic-embed-text:latest\n",
+		"qwen2.5": "        # the large one\n        - name: qwen2.5:14b # pulled by hand\n          max_input_tokens: 32768\n",
+	}
+	tests := []struct {
+		sort  string
+		order []string
+	}{
+		{sort: "name", order: []string{"llama3", "nomic", "qwen2.5"}},
+		{sort: "size", order: []string{"qwen2.5", "llama3", "nomic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			res := runMain(t, "", "-c", writeFile(t, "config.yaml", config), "--sort", tt.sort, "--set", "temperature=0.7")
+			if res.code != exitOK {
+				t.Fatalf("exit code %d: %s", res.code, res.stderr)
+			}
+			// the comments move along with the models, and the comment of a replaced value is kept
+			last := -1
+			for _, model := range tt.order {
+				i := strings.Index(res.stdout, entries[model])
+				if i < 0 {
+					t.Fatalf("comments of %s lost:\n%s", model, res.stdout)
+				}
+				if i < last {
+					t.Errorf("%s out of order:\n%s", model, res.stdout)
+				}
+				last = i
+			}
+			if !strings.Contains(res.stdout, "temperature: 0.7 # warm\n") {
+				t.Errorf("comment of the value lost:\n%s", res.stdout)
+			}
+		})
+	}
+}
+
+func TestCommentsOfRestoredModelKept(t *testing.T) {
+	config := `model: ollama:qwen2.5:14b
+clients:
+    - type: ollama
+      name: ollama
+      api_base: %s/v1
+      models:
+        - name: qwen2.5:14b
+        # the default
+        - name: llama3:latest # pulled by hand
+          max_input_tokens: 4096
+`
+	// llama3 is removed from the server and commented out, then it is back
+	removed := newOllamaMock(t, testModels[1])
+	res := runMain(t, "", "-c", writeFile(t, "config.yaml", fmt.Sprintf(config, removed.URL)), "--prune-mode", "comment")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	if strings.Contains(res.stdout, "- name: llama3:latest") {
+		t.Fatalf("model not pruned:\n%s", res.stdout)
+	}
+	back := newOllamaMock(t, testModels[:2]...)
+	res = runMain(t, "", "-c", writeFile(t, "config.yaml", strings.Replace(res.stdout, removed.URL, back.URL, 1)), "--prune-mode", "comment")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d: %s", res.code, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "        # the default\n        - name: llama3:latest # pulled by hand\n          max_input_tokens: 4096\n") {
+		t.Errorf("comments of the restored model lost:\n%s", res.stdout)
+	}
+}
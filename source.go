@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	olmmodel "github.com/ollama/ollama/types/model"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// modelSource lists the models of a client from its server and fetches their parameters.
+type modelSource interface {
+	// name returns the name of the source for logging.
+	name() string
+	// listModels returns the models on the server, with the details if the server lists them.
+	listModels() ([]ollamaModel, error)
+	// showModel returns the parameters of the model, the unknown ones are negative.
+	showModel(model string) (*modelParameters, error)
+}
+
+// detailedSource is implemented by the sources listing the details of the models, e.g. ollama.
+type detailedSource interface {
+	// modelDetails returns the details of the listed model.
+	modelDetails(model string) (modelDetails, bool)
+}
+
+// modelDetails holds the details of a model in the list of the server.
+type modelDetails struct {
+	digest         string
+	modifiedAt     time.Time
+	parameterCount float64 // 0 if unknown
+	size           int64
+	family         string // e.g. llama, empty if unknown
+	parameterSize  string // as listed, e.g. 8.0B
+	quantization   string // e.g. Q4_0
+}
+
+// modelParameters holds the parameters of a model, negative value means unknown.
+type modelParameters struct {
+	maxContextLength int
+	maxOutputTokens  int
+	embeddingLength  int
+	temperature      float64
+	topP             float64
+	capabilities     []olmmodel.Capability
+	reranker         bool                // ranking model by the model info
+	parameters       map[string][]string // all parameters of the model by the name
+}
+
+func newModelParameters() *modelParameters {
+	return &modelParameters{
+		maxContextLength: -1,
+		maxOutputTokens:  -1,
+		embeddingLength:  -1,
+		temperature:      -1.0,
+		topP:             -1.0,
+	}
+}
+
+// getSourceName returns the model source of the client, --models-file, --source and extra.source of the client
+// take precedence. Clients of type or name lmstudio are LM Studio, openai-compatible clients are listed by the
+// OpenAI models endpoint and any other type is taken as ollama.
+func getSourceName(client *yaml.Node) string {
+	clientType := ""
+	if node, ok := getNodeValue(client, "type", yaml.ScalarNode); ok {
+		clientType = node.Value
+	}
+	extraSource := ""
+	if extraNode, ok := getNodeValue(client, "extra", yaml.MappingNode); ok {
+		if node, ok := getNodeValue(extraNode, "source", yaml.ScalarNode); ok {
+			extraSource = node.Value
+		}
+	}
+	switch {
+	case optModelsFile != "":
+		return "models-file"
+	case optSource != "":
+		return optSource
+	case extraSource != "":
+		return extraSource
+	case clientType == "lmstudio" || clientName(client) == "lmstudio":
+		return "lmstudio"
+	case clientType == "openai-compatible":
+		return "openai-compatible"
+	default:
+		return "ollama"
+	}
+}
+
+// createModelSource creates the model source by its name, the extra hosts are the api_base of
+// other servers merged into the client.
+func createModelSource(sourceName, apiBase string, hosts []string, apiKey string, tlsOpts tlsOptions, proxyURL *url.URL) (modelSource, error) {
+	if len(hosts) > 0 && sourceName != "llama-server" && sourceName != "ollama" {
+		return nil, tracerr.Errorf("extra hosts are not supported by source: %s", sourceName)
+	}
+	if optRunningOnly && sourceName != "ollama" {
+		return nil, tracerr.Errorf("--running-only is not supported by source: %s", sourceName)
+	}
+	switch sourceName {
+	case "models-file":
+		return createModelsFileSource(optModelsFile)
+	case "llama-server":
+		return createLlamaServerSource(append([]string{apiBase}, hosts...), apiKey, tlsOpts, proxyURL)
+	case "lmstudio":
+		httpClient, u, err := createHTTPClient(apiBase, apiKey, tlsOpts, proxyURL)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if u == nil {
+			u = &url.URL{Scheme: "http", Host: "localhost:1234"}
+		}
+		return &lmstudioSource{httpClient: httpClient, baseURL: u}, nil
+	case "openai-compatible":
+		httpClient, u, err := createHTTPClient(apiBase, apiKey, tlsOpts, proxyURL)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if u == nil {
+			return nil, tracerr.New("api_base is required for openai-compatible client")
+		}
+		return &openaiSource{httpClient: httpClient, baseURL: u}, nil
+	case "ollama":
+		return createOllamaSource(append([]string{apiBase}, hosts...), apiKey, tlsOpts, proxyURL)
+	default:
+		return nil, tracerr.Errorf("unknown source: %s", sourceName)
+	}
+}
+
+// errModelNotFound is the error of showModel when the model is gone from the server since it was listed.
+var errModelNotFound = errors.New("model not found")
+
+// getModelParameters returns the parameters of the model from the source, the result is kept for the rest of the run.
+// The parameters of an alias are of the model on the server, and the context length is capped by --max-context.
+func getModelParameters(model string) (*modelParameters, error) {
+	if params, ok := modelParams[model]; ok {
+		return params, nil
+	}
+	params, err := modelSrc.showModel(realModelName(model))
+	// the completed fetches are counted, failed or not
+	fetchProgress.step(model)
+	if err != nil {
+		return params, tracerr.Wrap(err)
+	}
+	fillFromRegistry(realModelName(model), params)
+	if optMaxCtx > 0 && params.maxContextLength > optMaxCtx {
+		verboseInfo("cap context length of %s: %d -> %d", model, params.maxContextLength, optMaxCtx)
+		params.maxContextLength = optMaxCtx
+	}
+	modelParams[model] = params
+	return params, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stampPrefix starts the stamp of --stamp, a line of the head comment of the models of the client.
+const stampPrefix = "# managed by aichatconf"
+
+// setStamp sets the stamp on the models key of the client, replacing the previous one. The time of the last
+// sync is only renewed when the models are changed, so a sync without changes leaves the config as is.
+func setStamp(client *yaml.Node, changed bool) {
+	var key *yaml.Node
+	for i := 0; i+1 < len(client.Content); i += 2 {
+		if client.Content[i].Value == "models" {
+			key = client.Content[i]
+		}
+	}
+	if key == nil {
+		return
+	}
+	lines := []string{}
+	stamped := false
+	for _, line := range strings.Split(key.HeadComment, "\n") {
+		if strings.HasPrefix(line, stampPrefix) {
+			stamped = true
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if stamped && !changed {
+		return
+	}
+	v := version
+	if v == "" {
+		v = "dev"
+	}
+	stamp := fmt.Sprintf("%s %s, last sync %s, do not edit below", stampPrefix, v, time.Now().UTC().Format(time.RFC3339))
+	key.HeadComment = strings.Join(append(lines, stamp), "\n")
+	verboseDebug("stamp set: %s", stamp)
+}
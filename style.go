@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// applyStyle sets the style of the collections of the config by --style: block writes every mapping and
+// sequence in block style, flow writes each model of the clients on a line in flow style and the rest in
+// block style, and preserve keeps the style of each node as read, the new nodes are in block style.
+func applyStyle(root *yaml.Node) error {
+	switch optStyle {
+	case "", "block":
+		setBlockStyle(root)
+	case "flow":
+		setBlockStyle(root)
+		clients, _ := getNodeValue(root, "clients", yaml.SequenceNode)
+		if clients == nil {
+			return nil
+		}
+		for _, client := range clients.Content {
+			if models, ok := getNodeValue(client, "models", yaml.SequenceNode); ok {
+				for _, model := range models.Content {
+					model.Style |= yaml.FlowStyle
+				}
+			}
+		}
+	case "preserve":
+	default:
+		return tracerr.Errorf("unknown style: %s", optStyle)
+	}
+	return nil
+}
+
+// setBlockStyle clears the flow style of the collections of the node recursively, the scalars are kept
+// as quoted.
+func setBlockStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style &^= yaml.FlowStyle
+	}
+	for _, child := range node.Content {
+		setBlockStyle(child)
+	}
+}
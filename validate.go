@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/zrs01/aichatconf/internal/aichat"
+	"github.com/ztrue/tracerr"
+	"gopkg.in/yaml.v3"
+)
+
+// modelTypes are the model types known by aichat.
+var modelTypes = []string{"chat", "embedding", "reranker"}
+
+// validateConfig checks the config node decodes to the aichat config and the models are valid,
+// the error names the offending client and model.
+func validateConfig(node *yaml.Node) error {
+	clients, _ := getNodeValue(node, "clients", yaml.SequenceNode)
+	if clients != nil {
+		for i, cfgClient := range clients.Content {
+			clientRef := clientName(cfgClient)
+			if clientRef == "" {
+				clientRef = fmt.Sprintf("#%d", i+1)
+			}
+			models, _ := getNodeValue(cfgClient, "models", yaml.SequenceNode)
+			if models == nil {
+				continue
+			}
+			for j, cfgModel := range models.Content {
+				modelName := fmt.Sprintf("#%d", j+1)
+				if name, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode); ok && strings.TrimSpace(name.Value) != "" {
+					modelName = name.Value
+				}
+				var model aichat.ClientModel
+				if err := cfgModel.Decode(&model); err != nil {
+					return tracerr.Errorf("invalid model %s of client %s: %v", modelName, clientRef, err)
+				}
+				if strings.TrimSpace(model.Name) == "" {
+					return tracerr.Errorf("invalid model %s of client %s: empty name", modelName, clientRef)
+				}
+				if model.Type != "" && !lo.Contains(modelTypes, model.Type) {
+					return tracerr.Errorf("invalid model %s of client %s: unknown type: %s", model.Name, clientRef, model.Type)
+				}
+			}
+		}
+	}
+	var cfg aichat.ConfigStruct
+	if err := node.Decode(&cfg); err != nil {
+		return tracerr.Errorf("invalid config: %v", err)
+	}
+	return nil
+}
+
+// schemaFinding is a problem of the config against the schema at the line and column of the node.
+type schemaFinding struct {
+	line, column int
+	path         string
+	message      string
+}
+
+func (f schemaFinding) String() string {
+	return fmt.Sprintf("line %d, column %d: %s: %s", f.line, f.column, f.path, f.message)
+}
+
+// validateSchema checks the config node against aichat.ConfigStruct and returns the unknown keys and
+// the values of a wrong type in the order of the file. The free-form values, e.g. patch and extra, are not checked.
+func validateSchema(node *yaml.Node) []schemaFinding {
+	findings := []schemaFinding{}
+	checkSchema(node, reflect.TypeOf(aichat.ConfigStruct{}), "config", &findings)
+	sortFindings(findings)
+	return findings
+}
+
+// sortFindings sorts the findings in the order of the file.
+func sortFindings(findings []schemaFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].line != findings[j].line {
+			return findings[i].line < findings[j].line
+		}
+		return findings[i].column < findings[j].column
+	})
+}
+
+// numberRange is the range of a numeric key, inclusive.
+type numberRange struct {
+	min, max float64
+}
+
+// numberRanges are the ranges of the numeric keys of the config and of the models.
+var numberRanges = map[string]numberRange{
+	"max_input_tokens":     {1, math.Inf(1)},
+	"max_output_tokens":    {1, math.Inf(1)},
+	"max_tokens_per_chunk": {1, math.Inf(1)},
+	"default_chunk_size":   {1, math.Inf(1)},
+	"max_batch_size":       {1, math.Inf(1)},
+	"input_price":          {0, math.Inf(1)},
+	"output_price":         {0, math.Inf(1)},
+	"temperature":          {0, math.Inf(1)},
+	"top_p":                {0, 1},
+	"rag_top_k":            {1, math.Inf(1)},
+	"rag_chunk_size":       {1, math.Inf(1)},
+	"rag_chunk_overlap":    {0, math.Inf(1)},
+}
+
+// validateStructure checks the config node for the problems the schema cannot tell and returns them in the order
+// of the file: the duplicate clients, the models without a name, the duplicate models of a client, the default
+// model referring to no client or no model of the client, and the numbers out of range. The clients are named by
+// the type without a name, as aichat does.
+func validateStructure(root *yaml.Node) []schemaFinding {
+	findings := []schemaFinding{}
+	checkRanges(root, "config", &findings)
+	// models of the clients by the name, nil for a client without the list
+	clientModels := map[string]*yaml.Node{}
+	clients, _ := getNodeValue(root, "clients", yaml.SequenceNode)
+	if clients != nil {
+		for i, cfgClient := range clients.Content {
+			if cfgClient.Kind != yaml.MappingNode {
+				continue
+			}
+			clientName, nameNode := strconv.Itoa(i), cfgClient
+			if node, ok := getNodeValue(cfgClient, "name", yaml.ScalarNode); ok {
+				clientName, nameNode = node.Value, node
+			} else if node, ok := getNodeValue(cfgClient, "type", yaml.ScalarNode); ok {
+				clientName = node.Value
+			}
+			path := fmt.Sprintf("config.clients[%s]", clientName)
+			models, _ := getNodeValue(cfgClient, "models", yaml.SequenceNode)
+			// the default model is checked against the first of the duplicate clients
+			if _, ok := clientModels[clientName]; ok {
+				findings = append(findings, schemaFinding{nameNode.Line, nameNode.Column, path, "duplicate client " + clientName})
+			} else {
+				clientModels[clientName] = models
+			}
+			if models == nil {
+				continue
+			}
+			names := map[string]bool{}
+			for j, cfgModel := range models.Content {
+				if cfgModel.Kind != yaml.MappingNode {
+					continue
+				}
+				modelPath := fmt.Sprintf("%s.models[%d]", path, j)
+				name, ok := getNodeValue(cfgModel, "name", yaml.ScalarNode)
+				if !ok || strings.TrimSpace(name.Value) == "" {
+					findings = append(findings, schemaFinding{cfgModel.Line, cfgModel.Column, modelPath, "model without name"})
+				} else {
+					modelPath = fmt.Sprintf("%s.models[%s]", path, name.Value)
+					if names[name.Value] {
+						findings = append(findings, schemaFinding{name.Line, name.Column, modelPath, "duplicate model " + name.Value})
+					}
+					names[name.Value] = true
+				}
+				checkRanges(cfgModel, modelPath, &findings)
+			}
+		}
+	}
+	if model, ok := getNodeValue(root, "model", yaml.ScalarNode); ok && model.Value != "" {
+		clientName, modelName, _ := strings.Cut(model.Value, ":")
+		models, ok := clientModels[clientName]
+		if !ok {
+			findings = append(findings, schemaFinding{model.Line, model.Column, "config.model", "client of the default model not found: " + model.Value})
+		} else if models != nil && len(models.Content) > 0 && findModelNode(models, modelName) == nil {
+			// the models of a client without the list are known by aichat
+			findings = append(findings, schemaFinding{model.Line, model.Column, "config.model", "default model not found: " + model.Value})
+		}
+	}
+	sortFindings(findings)
+	return findings
+}
+
+// checkRanges appends the numeric keys of the mapping node out of their range to the findings, the values which
+// are not numbers are left to the schema.
+func checkRanges(node *yaml.Node, path string, findings *[]schemaFinding) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		r, ok := numberRanges[key.Value]
+		if !ok || value.Kind != yaml.ScalarNode {
+			continue
+		}
+		f, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil || (f >= r.min && f <= r.max) {
+			continue
+		}
+		expected := fmt.Sprintf("between %g and %g", r.min, r.max)
+		if math.IsInf(r.max, 1) {
+			expected = fmt.Sprintf("at least %g", r.min)
+		}
+		*findings = append(*findings, schemaFinding{value.Line, value.Column, path + "." + key.Value,
+			fmt.Sprintf("%s expected, found %s", expected, value.Value)})
+	}
+}
+
+// checkSchema checks the node against the type and appends the findings, the path names the node.
+func checkSchema(node *yaml.Node, t reflect.Type, path string, findings *[]schemaFinding) {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || node.Tag == "!!null" {
+		return
+	}
+	mismatch := func() {
+		*findings = append(*findings, schemaFinding{node.Line, node.Column, path,
+			fmt.Sprintf("%s expected, found %s", schemaTypeName(t), schemaNodeName(node))})
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			mismatch()
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			fields[name] = t.Field(i).Type
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				*findings = append(*findings, schemaFinding{key.Line, key.Column, path, "unknown key " + key.Value})
+				continue
+			}
+			checkSchema(node.Content[i+1], fieldType, path+"."+key.Value, findings)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			mismatch()
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkSchema(node.Content[i+1], t.Elem(), path+"."+node.Content[i].Value, findings)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			mismatch()
+			return
+		}
+		for i, item := range node.Content {
+			name := fmt.Sprintf("%s[%d]", path, i)
+			if nameNode, ok := getNodeValue(item, "name", yaml.ScalarNode); ok {
+				name = fmt.Sprintf("%s[%s]", path, nameNode.Value)
+			}
+			checkSchema(item, t.Elem(), name, findings)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode || node.Decode(reflect.New(t).Interface()) != nil {
+			mismatch()
+		}
+	}
+}
+
+// schemaTypeName returns the name of the type in the terms of YAML.
+func schemaTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list"
+	case reflect.Struct, reflect.Map:
+		return "mapping"
+	default:
+		return "string"
+	}
+}
+
+// schemaNodeName describes the node found.
+func schemaNodeName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	default:
+		return fmt.Sprintf("%s %q", strings.TrimPrefix(node.ShortTag(), "!!"), node.Value)
+	}
+}
+
+// validateFile validates the config file against the schema and prints the findings, an error is returned
+// if there are any.
+func validateFile() error {
+	cfg, err := loadAichatConfig()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	findings := append(validateSchema(cfg.doc.Content[0]), validateStructure(cfg.doc.Content[0])...)
+	sortFindings(findings)
+	for _, finding := range findings {
+		fmt.Println(finding)
+	}
+	if len(findings) > 0 {
+		return withExitCode(exitInvalid, tracerr.Errorf("%d problems found: %s", len(findings), optCfgFile))
+	}
+	verboseInfo("no problems found: %s", optCfgFile)
+	return nil
+}
+
+// verifyOutput decodes the output strictly to aichat.ConfigStruct and checks the invariants of the config:
+// every model has a name, the names of the models of a client are unique, and the default model refers to
+// a client and one of its models, unless --no-fix-default keeps it as is or the client is the one pruned by
+// --prune-clients, which is warned already. The keys unknown to aichat.ConfigStruct are warned only, the config
+// may have the keys of a newer aichat. The output is also checked to be stable, written the same when read back,
+// so a sync of the output against the same server changes nothing.
+func verifyOutput(body []byte, prunedClient string) error {
+	var cfg aichat.ConfigStruct
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && err != io.EOF {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return tracerr.Wrap(err)
+		}
+		// the strict decoding goes on after the unknown keys, the other errors fail the verification
+		for _, msg := range typeErr.Errors {
+			if !strings.Contains(msg, " not found in type ") {
+				return tracerr.Wrap(err)
+			}
+		}
+		for _, msg := range typeErr.Errors {
+			logrus.Warnf("unknown key of the aichat config: %s", msg)
+		}
+	}
+	for _, client := range cfg.Clients {
+		names := map[string]bool{}
+		for i, model := range client.Models {
+			if strings.TrimSpace(model.Name) == "" {
+				return tracerr.Errorf("model #%d of client %s has no name", i+1, aichatClientName(client))
+			}
+			if names[model.Name] {
+				return tracerr.Errorf("duplicate model of client %s: %s", aichatClientName(client), model.Name)
+			}
+			names[model.Name] = true
+		}
+	}
+	if cfg.Model != "" && !optNoFixDefault {
+		clientName, modelName, _ := strings.Cut(cfg.Model, ":")
+		client, ok := lo.Find(cfg.Clients, func(c aichat.Client) bool { return aichatClientName(c) == clientName })
+		if !ok && clientName != prunedClient {
+			return tracerr.Errorf("client of the default model not found: %s", cfg.Model)
+		}
+		// the models of a client without the list are known by aichat
+		if ok && len(client.Models) > 0 && !lo.ContainsBy(client.Models, func(m aichat.ClientModel) bool { return m.Name == modelName }) {
+			return tracerr.Errorf("default model not found: %s", cfg.Model)
+		}
+	}
+	return checkStable(body)
+}
+
+// aichatClientName returns the name of the client, or the type if the client has no name, as clientName.
+func aichatClientName(client aichat.Client) string {
+	if client.Name != "" {
+		return client.Name
+	}
+	return client.Type
+}
+
+// checkStable reads back the output as a config and marshals it again, the bytes must be the same.
+func checkStable(body []byte) error {
+	// the "---" starting the file is kept as read
+	body = bytes.TrimPrefix(body, []byte("---\n"))
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(body, doc); err != nil {
+		return tracerr.Wrap(err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	again, err := marshalConfig(doc)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(again), bytes.TrimSpace(body)) {
+		return tracerr.New("output is not stable, it is written differently when read back")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyOutput(t *testing.T) {
+	mock := newOllamaMock(t, testModels...)
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		warn   string
+	}{
+		{name: "client without a name", config: strings.Replace(ollamaConfig(mock.URL), "    name: ollama\n", "", 1)},
+		{name: "unknown key", config: "theme: dark\n" + ollamaConfig(mock.URL), warn: "field theme not found"},
+		{name: "default model of the pruned client", config: ollamaConfig(mock.URL) + "  - type: openai\n    name: remote\n",
+			args: []string{"--client", "ollama", "--prune-clients", "--exclude", "*", "--exclude-mode", "glob"},
+			warn: "default model refers to the removed client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runMain(t, "", append([]string{"-c", writeFile(t, "config.yaml", tt.config)}, tt.args...)...)
+			if res.code != exitOK {
+				t.Fatalf("exit code %d: %s", res.code, res.stderr)
+			}
+			if tt.warn != "" && !strings.Contains(res.stderr, tt.warn) {
+				t.Errorf("not warned: %s\n%s", tt.warn, res.stderr)
+			}
+			decodeConfig(t, res.stdout)
+		})
+	}
+}
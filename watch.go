@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ztrue/tracerr"
+)
+
+// maxWatchBackoff is the longest wait of --watch after the failed syncs, unless the interval is longer.
+const maxWatchBackoff = time.Hour
+
+// lastSummary is the summary of the last sync, logged by --watch and summed up by --all-ollama.
+var lastSummary syncSummary
+
+// watch syncs the config once and then on every interval until interrupted, the output file is
+// only written when changed. The config is read again by each sync, so the edits between the syncs are
+// kept. The logs of the syncs are quiet unless a change is applied, and the wait is doubled on each
+// failure in a row up to maxWatchBackoff.
+func watch(ctx context.Context, interval time.Duration) error {
+	if optOutFile == "" || optOutFile == "-" {
+		return tracerr.New("--watch requires --output")
+	}
+	if interval <= 0 {
+		return tracerr.Errorf("invalid --interval, a positive duration is expected: %s", interval)
+	}
+	// nobody is there to confirm
+	optYes = true
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	level := logrus.GetLevel()
+	verboseInfo("watch every %s: %s", interval, optOutFile)
+	failures := 0
+	for {
+		before, _ := os.ReadFile(optOutFile)
+		if level == logrus.InfoLevel {
+			logrus.SetLevel(logrus.WarnLevel)
+		}
+		lastSummary = syncSummary{}
+		err := syncOnce()
+		logrus.SetLevel(level)
+		wait := interval
+		if err != nil {
+			failures++
+			wait = watchBackoff(interval, failures)
+			// the failures are not warned again once the wait stops growing
+			if failures == 1 || wait > watchBackoff(interval, failures-1) {
+				logrus.Warnf("sync failed %d times in a row, retry in %s: %v", failures, wait, err)
+			}
+		} else {
+			if failures > 0 {
+				logrus.Infof("sync recovered after %d failures", failures)
+			}
+			failures = 0
+			if after, _ := os.ReadFile(optOutFile); !bytes.Equal(before, after) {
+				logSummary(lastSummary, "config updated: %s (%s)", optOutFile, watchChanges(lastSummary))
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			verboseInfo("watch stopped")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// watchBackoff returns the wait after the failures in a row, the interval doubled on each of them after the first.
+func watchBackoff(interval time.Duration, failures int) time.Duration {
+	wait := interval
+	for i := 1; i < failures && wait < maxWatchBackoff; i++ {
+		wait *= 2
+	}
+	return max(interval, min(wait, maxWatchBackoff))
+}
+
+// watchChanges describes the changes of the sync for the log of --watch.
+func watchChanges(summary syncSummary) string {
+	changes := []string{}
+	if len(summary.addedNames) > 0 {
+		changes = append(changes, "added: "+strings.Join(summary.addedNames, ", "))
+	}
+	if len(summary.removedNames) > 0 {
+		changes = append(changes, "removed: "+strings.Join(summary.removedNames, ", "))
+	}
+	if len(changes) == 0 {
+		return summary.String()
+	}
+	return strings.Join(changes, "; ")
+}